@@ -2,9 +2,36 @@ package main
 
 import (
 	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha3"
+	"crypto/subtle"
+	"encoding/binary"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
+	"hash"
+	"io"
+	"math"
+	"math/big"
+	"net/http"
 	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+	"unicode/utf8"
+	"unsafe"
 )
 
 // Keccakの状態配列のサイズ (1600 bits = 5x5x64)
@@ -16,7 +43,9 @@ const L = 6  // log2(W)
 const RATE = 1088
 const CAPACITY = 512
 
-// ラウンド定数
+//go:generate go run gentables.go
+
+// ラウンド定数 (RCとrは go run gentables.go が計算した tables_generated.go と TestGeneratedTables で照合する)
 var RC = []uint64{
 	0x0000000000000001, 0x0000000000008082, 0x800000000000808A,
 	0x8000000080008000, 0x000000000000808B, 0x0000000080000001,
@@ -46,33 +75,57 @@ func rotl64(x uint64, y int) uint64 {
 	return (x << uint(y)) | (x >> uint(64-y))
 }
 
-// θステップ
+// θステップ (列パリティと補正値をローカル変数で計算し、1パスで適用)
 func (s *state) theta() {
-	c := [5]uint64{}
-	d := [5]uint64{}
+	c0 := s.a[0][0] ^ s.a[0][1] ^ s.a[0][2] ^ s.a[0][3] ^ s.a[0][4]
+	c1 := s.a[1][0] ^ s.a[1][1] ^ s.a[1][2] ^ s.a[1][3] ^ s.a[1][4]
+	c2 := s.a[2][0] ^ s.a[2][1] ^ s.a[2][2] ^ s.a[2][3] ^ s.a[2][4]
+	c3 := s.a[3][0] ^ s.a[3][1] ^ s.a[3][2] ^ s.a[3][3] ^ s.a[3][4]
+	c4 := s.a[4][0] ^ s.a[4][1] ^ s.a[4][2] ^ s.a[4][3] ^ s.a[4][4]
 
-	for x := 0; x < 5; x++ {
-		c[x] = s.a[x][0] ^ s.a[x][1] ^ s.a[x][2] ^ s.a[x][3] ^ s.a[x][4]
+	d0 := c4 ^ rotl64(c1, 1)
+	d1 := c0 ^ rotl64(c2, 1)
+	d2 := c1 ^ rotl64(c3, 1)
+	d3 := c2 ^ rotl64(c4, 1)
+	d4 := c3 ^ rotl64(c0, 1)
+
+	for y := 0; y < 5; y++ {
+		s.a[0][y] ^= d0
+		s.a[1][y] ^= d1
+		s.a[2][y] ^= d2
+		s.a[3][y] ^= d3
+		s.a[4][y] ^= d4
 	}
+}
 
+// ρπの事前計算表 (添字は状態配列の格納順 5x+y)
+var rhoPiRot [25]int // ρの回転量
+var rhoPiDst [25]int // π後の格納位置
+
+func init() {
 	for x := 0; x < 5; x++ {
-		d[x] = c[(x+4)%5] ^ rotl64(c[(x+1)%5], 1)
 		for y := 0; y < 5; y++ {
-			s.a[x][y] ^= d[x]
+			// π: (x, y) -> (y, 2x+3y)
+			rhoPiRot[5*x+y] = r[x][y]
+			rhoPiDst[5*x+y] = 5*y + (2*x+3*y)%5
 		}
 	}
 }
 
 // ρとπステップ
 func (s *state) rhoPi() {
-	temp := s.a[1][0]
+	var b [25]uint64
+	k := 0
 	for x := 0; x < 5; x++ {
 		for y := 0; y < 5; y++ {
-			current := s.a[x][y]
-			s.a[x][y] = rotl64(temp, r[x][y])
-			temp = current
+			b[rhoPiDst[k]] = rotl64(s.a[x][y], rhoPiRot[k])
+			k++
 		}
 	}
+
+	for x := 0; x < 5; x++ {
+		s.a[x] = [5]uint64(b[5*x : 5*x+5])
+	}
 }
 
 // χステップ
@@ -96,29 +149,147 @@ func (s *state) iota(round int) {
 	s.a[0][0] ^= RC[round]
 }
 
+// 差し替え可能な置換 (レーンはFIPS 202の順序 x + 5y で並ぶ)
+// スカラー版・展開版・アセンブリ版などの実装を同じスポンジで比較するために使う
+type Permutation func(lanes *[25]uint64)
+
+// 組み込みのKeccak-f[1600]をPermutationの形で呼び出す
+func KeccakF1600(lanes *[25]uint64) {
+	var s state
+	s.fromLanes(lanes)
+	s.keccakF1600()
+	s.toLanes(lanes)
+}
+
+// FIPS 202の順序のレーン配列から状態を設定
+func (s *state) fromLanes(lanes *[25]uint64) {
+	for i := 0; i < 25; i++ {
+		s.a[i%5][i/5] = lanes[i]
+	}
+}
+
+// 状態をFIPS 202の順序のレーン配列に書き出す
+func (s *state) toLanes(lanes *[25]uint64) {
+	for i := 0; i < 25; i++ {
+		lanes[i] = s.a[i%5][i/5]
+	}
+}
+
+// 置換を実行 (permがnilなら組み込みのKeccak-f[1600])
+func (s *state) permute(perm Permutation) {
+	if perm == nil {
+		s.keccakF1600()
+		return
+	}
+	var lanes [25]uint64
+	s.toLanes(&lanes)
+	perm(&lanes)
+	s.fromLanes(&lanes)
+}
+
+// 置換の各ステップ後に呼ばれるトレース関数 (stepは "θ", "ρπ", "χ", "ι")
+type traceRound func(round int, step string, s state)
+
 // Keccak-f[1600]置換
 func (s *state) keccakF1600() {
+	s.keccakF1600Trace(nil)
+}
+
+// ステップごとに状態の複製をtraceへ渡すKeccak-f[1600]置換 (traceがnilなら通常の置換)
+func (s *state) keccakF1600Trace(trace traceRound) {
 	for i := 0; i < 24; i++ {
 		s.theta()
+		if trace != nil {
+			trace(i, "θ", *s)
+		}
 		s.rhoPi()
+		if trace != nil {
+			trace(i, "ρπ", *s)
+		}
 		s.chi()
+		if trace != nil {
+			trace(i, "χ", *s)
+		}
 		s.iota(i)
+		if trace != nil {
+			trace(i, "ι", *s)
+		}
 	}
 }
 
-// パディング
-func pad(message []byte, rate int) []byte {
-	remaining := rate - (len(message)*8)%rate
-	if remaining == 0 {
-		remaining = rate
+// 各ステップ後の状態をtraceへ渡しながらKeccak-f[1600]を実行する (レーンはFIPS 202の順序 x + 5y)
+// stepは "θ", "ρπ", "χ", "ι" の順に、roundは0〜23で呼ばれる。SetPermutationと組み合わせると
+// スポンジが実行するすべての置換の途中経過を公開されている中間値と比べられる
+// traceがnilならトレースせずに置換だけを行う
+func KeccakF1600Trace(lanes *[25]uint64, trace func(round int, step string, lanes [25]uint64)) {
+	var s state
+	s.fromLanes(lanes)
+	if trace == nil {
+		s.keccakF1600()
+	} else {
+		s.keccakF1600Trace(func(round int, step string, st state) {
+			var l [25]uint64
+			st.toLanes(&l)
+			trace(round, step, l)
+		})
+	}
+	s.toLanes(lanes)
+}
+
+// ラウンド数を減らしたKeccak-p[1600, rounds] (FIPS 202 Algorithm 7)
+// 24ラウンドのうち最後のroundsラウンド (ラウンド番号 24-rounds 〜 23) を実行する
+// 攻撃の学習・研究用であり、24未満のラウンド数に安全性はない
+func (s *state) keccakP1600(rounds int) {
+	for i := 12 + 2*L - rounds; i < 12+2*L; i++ {
+		s.theta()
+		s.rhoPi()
+		s.chi()
+		s.iota(i)
+	}
+}
+
+// ラウンド数を減らしたKeccak-p[1600, rounds]をPermutationとして返す (SetPermutation用)
+func ReducedKeccakP1600(rounds int) Permutation {
+	if rounds < 1 || rounds > 12+2*L {
+		panic(fmt.Sprintf("ReducedKeccakP1600: ラウンド数 %d は1〜24の範囲外です", rounds))
 	}
+	return func(lanes *[25]uint64) {
+		var s state
+		s.fromLanes(lanes)
+		s.keccakP1600(rounds)
+		s.toLanes(lanes)
+	}
+}
+
+// nバイトのメッセージに付けるパディングのバイト数 (1〜rate/8)
+// 32ビット環境でのオーバーフローを避けるため、ビット長ではなくバイト単位で計算する
+func padLength(n, rate int) int {
+	rateBytes := rate / 8
+	return rateBytes - n%rateBytes
+}
 
-	padLen := (remaining + 7) / 8
-	padding := make([]byte, padLen)
-	padding[0] = 0x06 // SHA-3のパディング
+// nバイトのメッセージに続けるドメイン分離バイト付きのpad10*1 (rateはビット単位)
+func padBytes(n, rate int, domain byte) []byte {
+	padding := make([]byte, padLength(n, rate))
+	padding[0] = domain
 	padding[len(padding)-1] |= 0x80
+	return padding
+}
+
+// messageBitLenビットのメッセージに続けるパディングのバイト列 (rateはビット単位)
+// 先頭にドメイン分離バイト (DomainSHA3 や DomainSHAKE など) を置き、末尾に0x80をORする
+// 独自のスポンジ方式でパディングを自分で吸収するためのもので、メッセージはバイト単位に限る
+func Pad101(messageBitLen, rate int, domain byte) []byte {
+	if messageBitLen < 0 || messageBitLen%8 != 0 || rate <= 0 || rate%8 != 0 {
+		panic(fmt.Sprintf("Pad101: メッセージ長 %d ビットとレート %d ビットはバイト境界に揃っている必要があります", messageBitLen, rate))
+	}
+	return padBytes(messageBitLen/8, rate, domain)
+}
 
-	return append(message, padding...)
+// パディング (容量を切り詰めてから追加するので、呼び出し側のスライスの余り領域は書き換えない)
+func pad(message []byte, rate int) []byte {
+	message = message[:len(message):len(message)]
+	return append(message, padBytes(len(message), rate, DomainSHA3)...) // SHA-3のパディング
 }
 
 // SHA3-256のメイン関数
@@ -126,23 +297,74 @@ func sha3_256(message []byte) []byte {
 	// 状態の初期化
 	s := new(state)
 
-	// パディング
+	// パディング (結果は必ずレートの倍数になる)
 	paddedMsg := pad(message, RATE)
+	if len(paddedMsg)%(RATE/8) != 0 {
+		panic("sha3_256: パディング後の長さがレートの倍数ではありません")
+	}
+
+	return sha3_256Padded(s, paddedMsg)
+}
+
+// 呼び出し側のバッファをパディングにも使うSHA3-256 (パディングのための割り当てをしない)
+// buf[len(buf):] からレートの倍数になるまでの領域をパディングで上書きするため、
+// cap(buf) は len(buf) + padLength(len(buf), RATE) 以上である必要がある (不足していればpanic)
+func sha3_256InPlace(buf []byte) []byte {
+	n := len(buf) + padLength(len(buf), RATE)
+	if cap(buf) < n {
+		panic(fmt.Sprintf("sha3_256InPlace: パディングに %d バイトの容量が必要ですが %d バイトしかありません", n, cap(buf)))
+	}
+
+	paddedMsg := buf[:n]
+	clear(paddedMsg[len(buf):])
+	paddedMsg[len(buf)] = DomainSHA3 // SHA-3のパディング
+	paddedMsg[n-1] |= 0x80
+
+	return sha3_256Padded(new(state), paddedMsg)
+}
+
+// 呼び出し側が用意した状態を作業領域に使うSHA3-256 (状態の割り当てと寿命は呼び出し側が管理する)
+// 前の計算の値が残らないよう、最初に状態をゼロにする。完全なブロックはdataから直接吸収し、
+// 最後のブロックのパディングはスタック上の配列で行う
+func sum256WithState(s *state, data []byte) []byte {
+	*s = state{}
+
+	full := len(data) - len(data)%(RATE/8)
+	absorbPadded(s, data[:full])
+
+	var last [RATE / 8]byte
+	copy(last[:], data[full:])
+	last[len(data)-full] = DomainSHA3 // SHA-3のパディング
+	last[len(last)-1] |= 0x80
+	absorbPadded(s, last[:])
+
+	return squeeze256(s)
+}
+
+// パディング済みのメッセージを吸収して256ビットを出力する
+func sha3_256Padded(s *state, paddedMsg []byte) []byte {
+	absorbPadded(s, paddedMsg)
+	return squeeze256(s)
+}
 
+// レートの倍数の長さのデータをブロックごとに吸収する
+func absorbPadded(s *state, paddedMsg []byte) {
 	// メッセージブロックの処理
 	for i := 0; i < len(paddedMsg); i += RATE / 8 {
+		block := paddedMsg[i : i+RATE/8]
+
 		// ブロックとXOR
 		for j := 0; j < RATE/8; j++ {
-			if i+j < len(paddedMsg) {
-				byteIndex := i + j
-				wordIndex := j / 8
-				bytePosition := j % 8
-				s.a[wordIndex%5][wordIndex/5] ^= uint64(paddedMsg[byteIndex]) << uint(bytePosition*8)
-			}
+			wordIndex := j / 8
+			bytePosition := j % 8
+			s.a[wordIndex%5][wordIndex/5] ^= uint64(block[j]) << uint(bytePosition*8)
 		}
 		s.keccakF1600()
 	}
+}
 
+// 状態から256ビットを出力する
+func squeeze256(s *state) []byte {
 	// 出力の生成（256ビット）
 	output := make([]byte, 32)
 	outIndex := 0
@@ -160,34 +382,3584 @@ func sha3_256(message []byte) []byte {
 	return output
 }
 
-func main() {
-	reader := bufio.NewReader(os.Stdin)
+// ストリーミング処理用のスポンジ
+// 内部状態を書き換えながら吸収するため、複数のゴルーチンから同時に使ってはならない
+// (共有する場合はConcurrentHasherを使う)
+type Sponge struct {
+	s      state
+	buf    []byte // 吸収待ちの端数データ (rate未満)
+	rate   int    // レート (バイト単位)
+	dsbyte byte   // ドメイン分離バイト
+	size   int    // 出力長 (バイト単位)
 
-	for {
-		fmt.Print("\nSHA3-256ハッシュ値を計算する文字列を入力してください (終了する場合は'q'を入力):\n> ")
+	borrowed bool // bufがWriteNoCopyで渡された呼び出し側のスライスを参照しているか
+	perms    int  // Keccak-f[1600]を実行した回数
 
-		input, err := reader.ReadString('\n')
-		if err != nil {
-			fmt.Println("入力エラー:", err)
-			continue
+	perm Permutation // 差し替えた置換 (nilなら組み込みのKeccak-f[1600])
+}
+
+// SHA3-256用のスポンジを生成
+func newSponge256() *Sponge {
+	return &Sponge{rate: RATE / 8, dsbyte: DomainSHA3, size: 32}
+}
+
+// SHAKE256用のスポンジを生成 (Sumは512ビットを出力)
+func newShake256() *Sponge {
+	return &Sponge{rate: RATE / 8, dsbyte: DomainSHAKE, size: 64}
+}
+
+// アルゴリズムごとのパラメータ
+type variant struct {
+	rate   int    // レート (バイト単位)
+	dsbyte byte   // ドメイン分離バイト
+	size   int    // 既定の出力長 (バイト単位)
+	xof    bool   // 出力長を自由に選べるか
+	mhCode uint64 // multihashのアルゴリズムコード
+}
+
+// ドメイン分離バイト (メッセージの後ろに付くサフィックスビットとpad10*1の最初の1をまとめたもの)
+// 新しい方式を試すときはOptions.Domainに任意の値を指定できる
+const (
+	DomainKeccak   byte = 0x01 // 旧Keccak (Keccak-256など、サフィックスなし)
+	DomainCShake   byte = 0x04 // cSHAKE (サフィックス 00)
+	DomainSHA3     byte = 0x06 // SHA3-224〜SHA3-512 (サフィックス 01)
+	DomainRawSHAKE byte = 0x07 // RawSHAKE (サフィックス 11)
+	DomainSHAKE    byte = 0x1f // SHAKE128/SHAKE256 (サフィックス 1111)
+)
+
+// ドメイン分離バイトがpad10*1の前に置けるかを検証する
+// 最上位の1がパディングの開始ビットになるため0は使えず、
+// 0x80以上はブロック末尾のパディングビットと重なる場合があるため使えない
+func validateDomain(domain byte) error {
+	if domain == 0 || domain >= 0x80 {
+		return fmt.Errorf("ドメイン分離バイトは0x01〜0x7fで指定してください: 0x%02x", domain)
+	}
+	return nil
+}
+
+// 各アルゴリズムの出力長とブロック長 (バイト単位、crypto/sha256 の Size と BlockSize に相当)
+// variantsはこれらの定数から作られるので、Paramsの値と常に一致する
+const (
+	Size224 = 28
+	Size256 = 32
+	Size384 = 48
+	Size512 = 64
+
+	BlockSize224 = 144
+	BlockSize256 = 136
+	BlockSize384 = 104
+	BlockSize512 = 72
+
+	// SHAKEのSizeは既定の出力長 (任意の長さを出力できる)
+	SizeShake128      = 32
+	SizeShake256      = 64
+	BlockSizeShake128 = 168
+	BlockSizeShake256 = 136
+)
+
+// 対応しているアルゴリズム
+var variants = map[string]variant{
+	"sha3-224": {rate: BlockSize224, dsbyte: DomainSHA3, size: Size224, mhCode: 0x17},
+	"sha3-256": {rate: BlockSize256, dsbyte: DomainSHA3, size: Size256, mhCode: 0x16},
+	"sha3-384": {rate: BlockSize384, dsbyte: DomainSHA3, size: Size384, mhCode: 0x15},
+	"sha3-512": {rate: BlockSize512, dsbyte: DomainSHA3, size: Size512, mhCode: 0x14},
+	"shake128": {rate: BlockSizeShake128, dsbyte: DomainSHAKE, size: SizeShake128, xof: true, mhCode: 0x18},
+	"shake256": {rate: BlockSizeShake256, dsbyte: DomainSHAKE, size: SizeShake256, xof: true, mhCode: 0x19},
+}
+
+// Newに渡す設定
+type Options struct {
+	Algorithm string // "sha3-256" や "shake256" など
+	Length    int    // 出力長 (バイト単位、0なら既定値)
+	Domain    byte   // ドメイン分離バイト (0ならアルゴリズムの既定値、Domain* 定数を参照)
+}
+
+// 名前からアルゴリズムのパラメータを引く
+func lookupVariant(name string) (variant, error) {
+	v, ok := variants[name]
+	if !ok {
+		return variant{}, fmt.Errorf("未対応のアルゴリズムです: %q", name)
+	}
+	return v, nil
+}
+
+// アルゴリズムのパラメータ (表示や検証用)
+type Params struct {
+	Name     string
+	Rate     int  // レート (ビット単位)
+	Capacity int  // キャパシティ (ビット単位)
+	Size     int  // 既定の出力長 (バイト単位)
+	Rounds   int  // Keccak-fのラウンド数
+	Domain   byte // ドメイン分離バイト
+	XOF      bool // 出力長を自由に選べるか
+}
+
+// アルゴリズム名からパラメータを返す
+func paramsFor(name string) (Params, error) {
+	v, err := lookupVariant(name)
+	if err != nil {
+		return Params{}, err
+	}
+	return Params{
+		Name:     name,
+		Rate:     v.rate * 8,
+		Capacity: B - v.rate*8,
+		Size:     v.size,
+		Rounds:   12 + 2*L,
+		Domain:   v.dsbyte,
+		XOF:      v.xof,
+	}, nil
+}
+
+// 設定を検証してパラメータを返す
+func (o Options) variant() (variant, error) {
+	v, err := lookupVariant(o.Algorithm)
+	if err != nil {
+		return variant{}, err
+	}
+
+	switch {
+	case o.Length == 0:
+	case o.Length < 0:
+		return variant{}, fmt.Errorf("出力長が不正です: %d", o.Length)
+	case !v.xof && o.Length != v.size:
+		return variant{}, fmt.Errorf("%s の出力長は %d バイト固定です: %d", o.Algorithm, v.size, o.Length)
+	default:
+		v.size = o.Length
+	}
+
+	if o.Domain != 0 {
+		if err := validateDomain(o.Domain); err != nil {
+			return variant{}, err
 		}
+		v.dsbyte = o.Domain
+	}
+	return v, nil
+}
 
-		input = strings.TrimSpace(input)
+// 設定に従ってスポンジを生成
+func New(opts Options) (*Sponge, error) {
+	v, err := opts.variant()
+	if err != nil {
+		return nil, err
+	}
+	return NewSponge(B-v.rate*8, v.dsbyte, v.size)
+}
 
-		if input == "q" {
-			fmt.Println("プログラムを終了します")
-			break
+// 任意のキャパシティ (ビット単位) でKeccak-f[1600]のスポンジを生成
+// レート (1600 - capacity) は正でバイト境界に揃っている必要がある
+// 標準外のキャパシティは実験用であり、安全性はcapacity/2ビットに下がる
+func NewSponge(capacity int, dsbyte byte, size int) (*Sponge, error) {
+	rate := B - capacity
+	if capacity < 0 || rate <= 0 || rate%8 != 0 {
+		return nil, fmt.Errorf("キャパシティ %d ではバイト境界に揃った正のレートになりません", capacity)
+	}
+	if size <= 0 {
+		return nil, fmt.Errorf("出力長が不正です: %d", size)
+	}
+	if err := validateDomain(dsbyte); err != nil {
+		return nil, err
+	}
+	return &Sponge{rate: rate / 8, dsbyte: dsbyte, size: size}, nil
+}
+
+// "shake256:64" や "sha3-384" のような アルゴリズム[:出力長] 形式の文字列を解釈
+func ParseSpec(s string) (Options, error) {
+	name, length, hasLength := strings.Cut(strings.ToLower(strings.TrimSpace(s)), ":")
+
+	opts := Options{Algorithm: name}
+	if hasLength {
+		n, err := strconv.Atoi(length)
+		if err != nil || n <= 0 {
+			return Options{}, fmt.Errorf("出力長が不正です: %q", length)
 		}
+		opts.Length = n
+	}
 
-		// ハッシュ値を計算
-		hash := sha3_256([]byte(input))
+	if _, err := opts.variant(); err != nil {
+		return Options{}, err
+	}
+	return opts, nil
+}
 
-		// 16進数に変換して表示
-		fmt.Printf("\n入力文字列: %s\n", input)
-		fmt.Printf("SHA3-256ハッシュ値: ")
-		for _, b := range hash {
-			fmt.Printf("%02x", b)
+// 置換を差し替える (nilで組み込みのKeccak-f[1600]に戻す)
+// これ以降の吸収・絞り出しとSum/Squeezeに使われる
+func (sp *Sponge) SetPermutation(perm Permutation) {
+	sp.perm = perm
+}
+
+// 吸収の途中でレートを変更する (マルチレート・スポンジの実験用、rateはビット単位)
+// ブロック境界でのみ変更でき、端数データが残っている場合はエラーを返す
+// Sum/Squeezeは複製に対して行われるため、スポンジ自体が絞り出し段階に入ることはない
+func (sp *Sponge) SetRate(rate int) error {
+	if rate <= 0 || rate > B || rate%8 != 0 {
+		return fmt.Errorf("レート %d はバイト境界に揃った 1600 以下の正の値ではありません", rate)
+	}
+	if len(sp.buf) > 0 {
+		return fmt.Errorf("端数データ (%d バイト) が残っているためレートを変更できません", len(sp.buf))
+	}
+	sp.rate = rate / 8
+	return nil
+}
+
+// ブロック先頭のlanes個のレーンを状態にXOR
+// amd64 (リトルエンディアン) では、8バイト境界に揃ったブロックを []uint64 として直接読む
+// runtime.GOARCHは定数なので、他のアーキテクチャでは移植版のみがコンパイルされる
+// (このリポジトリはファイルを指定してビルドするため、ファイル単位のビルドタグは効かない)
+func xorLanes(s *state, block []byte, lanes int) {
+	if lanes == 0 {
+		return
+	}
+	block = block[:lanes*8]
+
+	if runtime.GOARCH == "amd64" && uintptr(unsafe.Pointer(&block[0]))%8 == 0 {
+		words := unsafe.Slice((*uint64)(unsafe.Pointer(&block[0])), lanes)
+		for i, w := range words {
+			s.a[i%5][i/5] ^= w
+		}
+		return
+	}
+	xorLanesGeneric(s, block, lanes)
+}
+
+// xorLanesの移植版 (全アーキテクチャ共通)
+func xorLanesGeneric(s *state, block []byte, lanes int) {
+	for i := 0; i < lanes; i++ {
+		s.a[i%5][i/5] ^= binary.LittleEndian.Uint64(block[i*8:])
+	}
+}
+
+// 状態の先頭からlen(out)バイトをレーン単位で取り出す
+// len(out)が8の倍数でない場合 (出力の端数やレートが8バイトの倍数でないスポンジ) は、
+// 最後のレーンの下位バイトから必要な分だけを取り出す
+func extractLanes(s *state, out []byte) {
+	lanes := len(out) / 8
+	for i := 0; i < lanes; i++ {
+		binary.LittleEndian.PutUint64(out[i*8:], s.a[i%5][i/5])
+	}
+	if rest := out[lanes*8:]; len(rest) > 0 {
+		lane := s.a[lanes%5][lanes/5]
+		for j := range rest {
+			rest[j] = byte(lane >> uint(j*8))
+		}
+	}
+}
+
+// 1ブロック分をレーン単位でXORして置換
+func (sp *Sponge) absorbBlock(block []byte) {
+	lanes := sp.rate / 8
+	xorLanes(&sp.s, block, lanes)
+
+	// レートが8バイトの倍数でない場合の最後の部分レーン
+	for j := lanes * 8; j < sp.rate; j++ {
+		wordIndex := j / 8
+		bytePosition := j % 8
+		sp.s.a[wordIndex%5][wordIndex/5] ^= uint64(block[j]) << uint(bytePosition*8)
+	}
+	sp.s.permute(sp.perm)
+	sp.perms++
+}
+
+// レート長ちょうどの1ブロックをパディングなしでXORして置換する (上級者向けの低レベルAPI)
+// 生の状態やパディング済みのブロックを注入する独自方式の実験用で、SHA-3のパディングや
+// ドメイン分離は呼び出し側の責任になる。誤った使い方をすると標準のSHA-3とは無関係な値になる
+// ブロック長がレートと異なる場合や、Writeの端数データが残っている場合はpanicする
+func (sp *Sponge) AbsorbBlock(block []byte) {
+	if len(block) != sp.rate {
+		panic(fmt.Sprintf("AbsorbBlock: ブロック長 %d がレート %d と一致しません", len(block), sp.rate))
+	}
+	if len(sp.buf) > 0 {
+		panic(fmt.Sprintf("AbsorbBlock: 端数データ (%d バイト) が残っています", len(sp.buf)))
+	}
+	sp.absorbBlock(block)
+}
+
+// 呼び出し側のスライスを複製せずに吸収する
+// 完全なブロックはpから直接レーン単位でXORし、残りの端数もコピーせずにpの末尾を参照したまま保持する
+// 所有権の約束: 次にこのスポンジのメソッドを呼ぶまで、呼び出し側はpの内容を変更してはならない
+// 端数データが既に残っている場合は、通常のWriteと同じく複製して吸収する
+func (sp *Sponge) WriteNoCopy(p []byte) {
+	if len(sp.buf) > 0 {
+		sp.Write(p)
+		return
+	}
+
+	for len(p) >= sp.rate {
+		sp.absorbBlock(p[:sp.rate])
+		p = p[sp.rate:]
+	}
+	if len(p) > 0 {
+		sp.buf = p[:len(p):len(p)]
+		sp.borrowed = true
+	}
+}
+
+// WriteNoCopyで借りている端数データを自前のバッファに移す
+func (sp *Sponge) unborrow() {
+	if sp.borrowed {
+		sp.buf = append([]byte(nil), sp.buf...)
+		sp.borrowed = false
+	}
+}
+
+// データの吸収 (io.Writer)
+func (sp *Sponge) Write(p []byte) (int, error) {
+	n := len(p)
+	sp.unborrow()
+
+	// 端数バッファを先に埋める
+	if len(sp.buf) > 0 {
+		m := sp.rate - len(sp.buf)
+		if m > len(p) {
+			m = len(p)
+		}
+		sp.buf = append(sp.buf, p[:m]...)
+		p = p[m:]
+		if len(sp.buf) == sp.rate {
+			sp.absorbBlock(sp.buf)
+			sp.buf = sp.buf[:0]
+		}
+	}
+
+	// 完全なブロックは直接吸収
+	for len(p) >= sp.rate {
+		sp.absorbBlock(p[:sp.rate])
+		p = p[sp.rate:]
+	}
+
+	sp.buf = append(sp.buf, p...)
+	return n, nil
+}
+
+// 端数にパディングを施して最後のブロックを吸収
+func (sp *Sponge) finalize() {
+	block := make([]byte, sp.rate)
+	copy(block, sp.buf)
+	block[len(sp.buf)] ^= sp.dsbyte
+	block[sp.rate-1] ^= 0x80
+	sp.absorbBlock(block)
+	sp.buf = sp.buf[:0]
+}
+
+// 状態から出力を絞り出す
+func (sp *Sponge) squeeze(out []byte) {
+	for len(out) > 0 {
+		n := len(out)
+		if n > sp.rate {
+			n = sp.rate
+		}
+		extractLanes(&sp.s, out[:n])
+		out = out[n:]
+		if len(out) > 0 {
+			sp.s.permute(sp.perm)
+			sp.perms++
+		}
+	}
+}
+
+// 現在の状態の複製を返す
+func (sp *Sponge) Clone() *Sponge {
+	d := *sp
+	d.buf = append([]byte(nil), sp.buf...)
+	d.borrowed = false
+	return &d
+}
+
+// シリアライズ形式の識別子と固定部分のサイズ
+const spongeMagic = "sp3\x01"
+const spongeMarshaledSize = len(spongeMagic) + 2 + 4 + 25*8
+
+// 吸収の途中状態をシリアライズ (encoding.BinaryMarshaler)
+func (sp *Sponge) MarshalBinary() ([]byte, error) {
+	b := make([]byte, 0, spongeMarshaledSize+len(sp.buf))
+	b = append(b, spongeMagic...)
+	b = append(b, byte(sp.rate), sp.dsbyte)
+	b = binary.BigEndian.AppendUint32(b, uint32(sp.size))
+
+	// レーンはFIPS 202の順序 (x + 5y) で格納
+	for i := 0; i < 25; i++ {
+		b = binary.LittleEndian.AppendUint64(b, sp.s.a[i%5][i/5])
+	}
+	return append(b, sp.buf...), nil
+}
+
+// シリアライズした状態を復元 (encoding.BinaryUnmarshaler)
+func (sp *Sponge) UnmarshalBinary(b []byte) error {
+	if len(b) < spongeMarshaledSize || string(b[:len(spongeMagic)]) != spongeMagic {
+		return errors.New("スポンジの状態が不正です")
+	}
+	b = b[len(spongeMagic):]
+
+	rate, dsbyte, size := int(b[0]), b[1], int(binary.BigEndian.Uint32(b[2:]))
+	buf := b[6+25*8:]
+	if rate == 0 || rate > B/8 || len(buf) >= rate || validateDomain(dsbyte) != nil {
+		return errors.New("スポンジのレート、ドメイン分離バイトまたは端数データが不正です")
+	}
+	if size <= 0 {
+		return fmt.Errorf("スポンジの出力長が不正です: %d", size)
+	}
+	b = b[6:]
+
+	sp.rate, sp.dsbyte, sp.size = rate, dsbyte, size
+	for i := 0; i < 25; i++ {
+		sp.s.a[i%5][i/5] = binary.LittleEndian.Uint64(b[i*8:])
+	}
+	if sp.borrowed {
+		sp.buf, sp.borrowed = nil, false
+	}
+	sp.buf = append(sp.buf[:0], buf...)
+	return nil
+}
+
+// 吸収を終えた絞り出し直前の内部状態を返す (スポンジの状態は変更しない)
+func (sp *Sponge) absorbedState() state {
+	d := sp.Clone()
+	d.finalize()
+	return d.s
+}
+
+// ダイジェストをbに追加して返す (スポンジの状態は変更しない)
+func (sp *Sponge) Sum(b []byte) []byte {
+	d := sp.Clone()
+	d.finalize()
+
+	hash := make([]byte, d.size)
+	d.squeeze(hash)
+	return append(b, hash...)
+}
+
+// 32バイトのダイジェストをdstに書き込む (割り当てをしないSum、スポンジの状態は変更しない)
+// Sumと違い複製や端数バッファの割り当てをせず、最後のブロックのパディングはスタック上で行う
+// 出力長が32バイトのスポンジ (SHA3-256など) 専用で、それ以外ではpanicする
+func (sp *Sponge) SumInto(dst *[32]byte) {
+	if sp.size != 32 {
+		panic(fmt.Sprintf("SumInto: 出力長が32バイトではありません: %d", sp.size))
+	}
+
+	var block [B / 8]byte
+	n := copy(block[:sp.rate], sp.buf)
+	block[n] ^= sp.dsbyte
+	block[sp.rate-1] ^= 0x80
+
+	d := Sponge{rate: sp.rate, s: sp.s, perm: sp.perm}
+	d.absorbBlock(block[:sp.rate])
+	d.squeeze(dst[:])
+}
+
+// これまでの吸収でKeccak-f[1600]を実行した回数
+// Sum/Squeezeは複製に対して置換するのでこの値を変えない (最後のブロックや絞り出しの分を含めた
+// 通算の回数はSqueezer.Permutationsで分かる)
+func (sp *Sponge) Permutations() int { return sp.perms }
+
+// 初期状態に戻す
+func (sp *Sponge) Reset() {
+	sp.Zeroize()
+	sp.perms = 0
+}
+
+// 秘密情報に由来する状態を消去する (25レーンと端数バッファ全体をゼロで上書き)
+// GCが移動・複製したメモリまでは消せないため、ベストエフォートの対策である
+// WriteNoCopyで借りている呼び出し側のスライスには触れず、参照だけを手放す
+func (sp *Sponge) Zeroize() {
+	sp.s = state{}
+	if sp.borrowed {
+		sp.buf, sp.borrowed = nil, false
+	}
+	clear(sp.buf[:cap(sp.buf)])
+	sp.buf = sp.buf[:0]
+}
+
+// 出力長 (バイト単位)
+func (sp *Sponge) Size() int { return sp.size }
+
+// ブロック長 (バイト単位)
+func (sp *Sponge) BlockSize() int { return sp.rate }
+
+// C言語のSHA-3実装によくある init/update/final の3関数に対応する薄いラッパー
+// 参照実装のCコードをそのまま移植しやすくするためのもので、中身はSpongeの操作である
+
+// レート (ビット単位) とドメイン分離バイトを指定してスポンジを初期化する
+// (SHA3-256なら initSponge(1088, DomainSHA3))。レートが不正ならpanicする
+func initSponge(rate int, domain byte) *Sponge {
+	sp, err := NewSponge(B-rate, domain, 1)
+	if err != nil {
+		panic("initSponge: " + err.Error())
+	}
+	return sp
+}
+
+// データを吸収する
+func update(s *Sponge, data []byte) {
+	s.Write(data)
+}
+
+// パディングして outLen バイトを絞り出す (スポンジの状態は変更しない)
+func final(s *Sponge, outLen int) []byte {
+	out := make([]byte, outLen)
+	s.Squeeze().Read(out)
+	return out
+}
+
+// 複数のスライスを連結せずに1つのメッセージとして吸収したSHA3-256 (writevに相当)
+// sha3_256(bytes.Join(chunks, nil)) と同じ値になる
+func sum256Vectored(chunks ...[]byte) []byte {
+	sp := newSponge256()
+	for _, c := range chunks {
+		sp.Write(c)
+	}
+	return sp.Sum(nil)
+}
+
+// リーダーのSHA3-256と吸収したバイト数を返す
+// 途中で読み込みエラーが起きた場合も、nはそれまでに読み込んだバイト数を表す
+func Sum256ReaderN(r io.Reader) (digest []byte, n int64, err error) {
+	sp := newSponge256()
+	n, err = io.Copy(sp, r)
+	if err != nil {
+		return nil, n, err
+	}
+	return sp.Sum(nil), n, nil
+}
+
+// パッケージ内で使うSum256ReaderNの別名 (ログやメトリクス用に、ダイジェストと同時にバイト数を得る)
+func sum256Counted(r io.Reader) (digest []byte, n int64, err error) {
+	return Sum256ReaderN(r)
+}
+
+// 複数のゴルーチンから同時に呼び出せるSHA3-256 (ゼロ値のまま使用できる)
+// 呼び出しごとにプールからスポンジを借りるので、1つの値をハンドラ間で共有できる
+type ConcurrentHasher struct {
+	pool sync.Pool
+}
+
+// dataのSHA3-256を返す
+func (ch *ConcurrentHasher) Sum256(data []byte) []byte {
+	sp, _ := ch.pool.Get().(*Sponge)
+	if sp == nil {
+		sp = newSponge256()
+	}
+	sp.Write(data)
+	digest := sp.Sum(nil)
+
+	// 借りたスポンジは初期状態に戻して返す (入力に由来する状態を残さない)
+	sp.Reset()
+	ch.pool.Put(sp)
+	return digest
+}
+
+// dataのSHA3-256をdstに書き込む (プールのスポンジが温まった後は割り当てをしない)
+func (ch *ConcurrentHasher) Sum256Into(data []byte, dst *[32]byte) {
+	sp, _ := ch.pool.Get().(*Sponge)
+	if sp == nil {
+		sp = newSponge256()
+	}
+	sp.Write(data)
+	sp.SumInto(dst)
+
+	sp.Reset()
+	ch.pool.Put(sp)
+}
+
+// rのoffからlengthバイトの範囲だけを読んでSHA3-256を返す (*os.Fileならシークせずに読める)
+// 範囲が入力の終わりを超えている場合や読み込みエラーはエラーとして返す
+func Sum256Range(r io.ReaderAt, off, length int64) ([]byte, error) {
+	if off < 0 || length < 0 {
+		return nil, fmt.Errorf("範囲が不正です (オフセット %d、長さ %d)", off, length)
+	}
+
+	sp := newSponge256()
+	n, err := io.Copy(sp, io.NewSectionReader(r, off, length))
+	if err != nil {
+		return nil, err
+	}
+	if n != length {
+		return nil, fmt.Errorf("範囲 [%d, %d) が入力の終わりを超えています (%d バイトしか読めません)", off, off+length, n)
+	}
+	return sp.Sum(nil), nil
+}
+
+// XOFの出力を任意の長さだけ読み出すスクイーザー
+type Squeezer struct {
+	s     state
+	rate  int // レート (バイト単位)
+	off   int // 現在のブロック内の読み出し位置
+	perms int // 吸収から通算したKeccak-f[1600]の実行回数
+
+	perm Permutation // 差し替えた置換 (nilなら組み込みのKeccak-f[1600])
+}
+
+// 吸収を終えて絞り出しを開始する (スポンジの状態は変更しない)
+func (sp *Sponge) Squeeze() *Squeezer {
+	d := sp.Clone()
+	d.finalize()
+	return &Squeezer{s: d.s, rate: d.rate, perms: d.perms, perm: d.perm}
+}
+
+// 吸収から通算したKeccak-f[1600]の実行回数
+func (sq *Squeezer) Permutations() int { return sq.perms }
+
+// 出力の読み出し (io.Reader)
+func (sq *Squeezer) Read(p []byte) (int, error) {
+	for i := range p {
+		if sq.off == sq.rate {
+			sq.s.permute(sq.perm)
+			sq.perms++
+			sq.off = 0
 		}
-		fmt.Println()
+		wordIndex := sq.off / 8
+		p[i] = byte(sq.s.a[wordIndex%5][wordIndex/5] >> uint((sq.off%8)*8))
+		sq.off++
+	}
+	return len(p), nil
+}
+
+// 絞り出し中の状態を消去する
+func (sq *Squeezer) Zeroize() {
+	sq.s = state{}
+	sq.off = 0
+}
+
+// シリアライズ形式の識別子とサイズ
+const squeezerMagic = "sq3\x01"
+const squeezerMarshaledSize = len(squeezerMagic) + 2 + 25*8
+
+// 絞り出しの途中状態をシリアライズ (encoding.BinaryMarshaler)
+func (sq *Squeezer) MarshalBinary() ([]byte, error) {
+	b := make([]byte, 0, squeezerMarshaledSize)
+	b = append(b, squeezerMagic...)
+	b = append(b, byte(sq.rate), byte(sq.off))
+
+	// レーンはFIPS 202の順序 (x + 5y) で格納
+	for i := 0; i < 25; i++ {
+		b = binary.LittleEndian.AppendUint64(b, sq.s.a[i%5][i/5])
+	}
+	return b, nil
+}
+
+// シリアライズした状態を復元 (encoding.BinaryUnmarshaler)
+func (sq *Squeezer) UnmarshalBinary(b []byte) error {
+	if len(b) != squeezerMarshaledSize || string(b[:len(squeezerMagic)]) != squeezerMagic {
+		return errors.New("スクイーザーの状態が不正です")
+	}
+	b = b[len(squeezerMagic):]
+
+	rate, off := int(b[0]), int(b[1])
+	if rate == 0 || rate > B/8 || off > rate {
+		return errors.New("スクイーザーのレートまたはオフセットが不正です")
+	}
+	b = b[2:]
+
+	sq.rate, sq.off = rate, off
+	for i := 0; i < 25; i++ {
+		sq.s.a[i%5][i/5] = binary.LittleEndian.Uint64(b[i*8:])
+	}
+	return nil
+}
+
+// SP 800-185 の left_encode
+func leftEncode(x uint64) []byte {
+	var b [9]byte
+	binary.BigEndian.PutUint64(b[1:], x)
+	i := 1
+	for i < 8 && b[i] == 0 {
+		i++
+	}
+	b[i-1] = byte(9 - i)
+	return b[i-1:]
+}
+
+// SP 800-185 の right_encode
+func rightEncode(x uint64) []byte {
+	var b [9]byte
+	binary.BigEndian.PutUint64(b[:8], x)
+	i := 0
+	for i < 7 && b[i] == 0 {
+		i++
+	}
+	b[8] = byte(8 - i)
+	return b[i:]
+}
+
+// SP 800-185 の encode_string
+func encodeString(s []byte) []byte {
+	return append(leftEncode(uint64(len(s))*8), s...)
+}
+
+// SP 800-185 の bytepad (wバイトの倍数までゼロで埋める)
+func bytepad(x []byte, w int) []byte {
+	b := append(leftEncode(uint64(w)), x...)
+	if rem := len(b) % w; rem != 0 {
+		b = append(b, make([]byte, w-rem)...)
+	}
+	return b
+}
+
+// cSHAKE256用のスポンジを生成 (関数名nとカスタマイズ文字列s)
+func newCShake256(n, s []byte) *Sponge {
+	if len(n) == 0 && len(s) == 0 {
+		return newShake256()
+	}
+	sp := &Sponge{rate: RATE / 8, dsbyte: DomainCShake, size: 64}
+	sp.Write(bytepad(append(encodeString(n), encodeString(s)...), sp.rate))
+	return sp
+}
+
+// personalizationをカスタマイズ文字列にしたcSHAKE256の先頭32バイト
+// personalizationが異なれば互いに独立したハッシュ関数として扱え、用途ごとにダイジェストを分けられる
+// (空のpersonalizationはSHAKE256の32バイト出力と一致する)
+func sum256Personalized(data, personalization []byte) []byte {
+	sp := newCShake256(nil, personalization)
+	sp.Write(data)
+
+	output := make([]byte, 32)
+	sp.Squeeze().Read(output)
+	return output
+}
+
+// 鍵とカスタマイズ文字列を吸収済みのKMAC256用スポンジを生成
+func newKMAC256Sponge(key, customization []byte) *Sponge {
+	sp := newCShake256([]byte("KMAC"), customization)
+	sp.Write(bytepad(encodeString(key), sp.rate))
+	return sp
+}
+
+// KMAC256の共通処理 (outBitsが0ならXOFモード)
+func kmac256(key, message, customization []byte, outBits uint64, output []byte) {
+	sp := newKMAC256Sponge(key, customization)
+	sp.Write(message)
+	sp.Write(rightEncode(outBits))
+
+	sq := sp.Squeeze()
+	sq.Read(output)
+
+	// 鍵に由来する状態を残さない
+	sq.Zeroize()
+	sp.Zeroize()
+}
+
+// KMAC256 (outLenバイトのMACを返す)
+func KMAC256(key, message, customization []byte, outLen int) []byte {
+	output := make([]byte, outLen)
+	kmac256(key, message, customization, uint64(outLen)*8, output)
+	return output
+}
+
+// KMACXOF256 (outputの長さだけMACを出力する)
+func KMACXOF256(key, message, customization []byte, output []byte) {
+	kmac256(key, message, customization, 0, output)
+}
+
+// メッセージをストリーミングで吸収するKMAC256 (hash.Hash)
+// 出力長のエンコード right_encode(outLen*8) はSumの時点で複製に対してだけ吸収するので、
+// Sumの後もWriteを続けられる
+type kmacHash struct {
+	sp     *Sponge
+	keyed  *Sponge // Reset用の鍵を吸収した直後の状態
+	outLen int
+}
+
+// KMAC256をhash.Hashとして生成 (Sumは KMAC256(key, 書き込んだメッセージ, customization, outLen) と一致する)
+// outLenが正でなければpanicする
+func NewKMAC256(key, customization []byte, outLen int) hash.Hash {
+	if outLen <= 0 {
+		panic(fmt.Sprintf("NewKMAC256: 出力長が不正です: %d", outLen))
+	}
+	sp := newKMAC256Sponge(key, customization)
+	return &kmacHash{sp: sp, keyed: sp.Clone(), outLen: outLen}
+}
+
+func (k *kmacHash) Write(p []byte) (int, error) { return k.sp.Write(p) }
+
+// MACをbに追加して返す (書き込んだメッセージの状態は変更しない)
+func (k *kmacHash) Sum(b []byte) []byte {
+	d := k.sp.Clone()
+	d.Write(rightEncode(uint64(k.outLen) * 8))
+	sq := d.Squeeze()
+
+	output := make([]byte, k.outLen)
+	sq.Read(output)
+
+	// 鍵に由来する状態を残さない
+	sq.Zeroize()
+	d.Zeroize()
+	return append(b, output...)
+}
+
+// 鍵を吸収した直後の状態に戻す
+func (k *kmacHash) Reset() {
+	k.sp.Zeroize()
+	k.sp = k.keyed.Clone()
+}
+
+func (k *kmacHash) Size() int      { return k.outLen }
+func (k *kmacHash) BlockSize() int { return k.sp.BlockSize() }
+
+// TupleHash256 (各要素の境界を保ったまま要素列をハッシュする)
+func tupleHash256(tuple [][]byte, customization []byte, outLen int) []byte {
+	sp := newCShake256([]byte("TupleHash"), customization)
+	for _, x := range tuple {
+		sp.Write(leftEncode(uint64(len(x)) * 8))
+		sp.Write(x)
+	}
+	sp.Write(rightEncode(uint64(outLen) * 8))
+
+	output := make([]byte, outLen)
+	sp.Squeeze().Read(output)
+	return output
+}
+
+// (名前, サイズ, 内容) の3要素をTupleHash256 (32バイト出力) でハッシュする
+// 名前やサイズもダイジェストに含まれるため、内容だけをハッシュした値とは互換性がない
+// 内容はrからストリーミングで吸収し、sizeバイトちょうどでなければエラーを返す
+func SumWithMeta256(name string, size int64, r io.Reader) ([]byte, error) {
+	sp := newCShake256([]byte("TupleHash"), nil)
+	for _, x := range [][]byte{[]byte(name), binary.BigEndian.AppendUint64(nil, uint64(size))} {
+		sp.Write(leftEncode(uint64(len(x)) * 8))
+		sp.Write(x)
+	}
+
+	sp.Write(leftEncode(uint64(size) * 8))
+	n, err := io.Copy(sp, r)
+	if err != nil {
+		return nil, err
+	}
+	if n != size {
+		return nil, fmt.Errorf("読み込み中に内容の長さが変わりました (%d バイトのはずが %d バイト)", size, n)
+	}
+	sp.Write(rightEncode(32 * 8))
+
+	output := make([]byte, 32)
+	sp.Squeeze().Read(output)
+	return output, nil
+}
+
+// 値を正規のバイト列にエンコードしてSHA3-256でハッシュする (結果をキャッシュするキーなどに使う)
+// 意味の等しい値は同じダイジェストになる: マップはキーのエンコードの順に並べ、構造体は
+// 公開フィールドを宣言順に名前付きで、スライス・配列・文字列は長さを前置して書き込む
+// 整数は型の幅によらず同じ値なら同じエンコードになる (int8(1) と int64(1) は等しい)
+// nilのスライス・マップはnilとしてエンコードするので、空のスライス・マップとは別のダイジェストになる
+// (encoding/jsonの null と [] / {} の区別と同じ)。同一視したい場合は呼び出し側で空の値にそろえる
+// 非公開フィールドは含めない。チャネル・関数・unsafe.Pointer、深さvalueMaxDepthを超える
+// 入れ子 (ポインタの循環など) はエラーになる
+func SumValue256(v any) ([]byte, error) {
+	sp := newSponge256()
+	if err := encodeValue(sp, reflect.ValueOf(v), 0); err != nil {
+		return nil, err
+	}
+	return sp.Sum(nil), nil
+}
+
+// SumValue256でたどる入れ子の深さの上限
+const valueMaxDepth = 100
+
+// SumValue256のエンコードで値の種類を区別するタグ
+const (
+	tagNil    = 'n'
+	tagBool   = 'b'
+	tagInt    = 'i'
+	tagUint   = 'u'
+	tagFloat  = 'f'
+	tagString = 's'
+	tagBytes  = 'y'
+	tagList   = 'l'
+	tagMap    = 'm'
+	tagStruct = 'S'
+)
+
+// 値を種類のタグ付きでwに書き込む
+func encodeValue(w io.Writer, v reflect.Value, depth int) error {
+	if depth > valueMaxDepth {
+		return fmt.Errorf("入れ子が深すぎます (循環していないか確認してください): %d 段を超えています", valueMaxDepth)
+	}
+	if !v.IsValid() {
+		_, err := w.Write([]byte{tagNil})
+		return err
+	}
+
+	var b []byte
+	switch v.Kind() {
+	case reflect.Bool:
+		b = []byte{tagBool, 0}
+		if v.Bool() {
+			b[1] = 1
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		b = binary.BigEndian.AppendUint64([]byte{tagInt}, uint64(v.Int()))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		b = binary.BigEndian.AppendUint64([]byte{tagUint}, v.Uint())
+	case reflect.Float32, reflect.Float64:
+		b = binary.BigEndian.AppendUint64([]byte{tagFloat}, math.Float64bits(v.Float()))
+	case reflect.Complex64, reflect.Complex128:
+		c := v.Complex()
+		b = binary.BigEndian.AppendUint64([]byte{tagFloat}, math.Float64bits(real(c)))
+		b = binary.BigEndian.AppendUint64(append(b, tagFloat), math.Float64bits(imag(c)))
+	case reflect.String:
+		b = append([]byte{tagString}, encodeString([]byte(v.String()))...)
+
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			_, err := w.Write([]byte{tagNil})
+			return err
+		}
+		return encodeValue(w, v.Elem(), depth+1)
+
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			_, err := w.Write([]byte{tagNil})
+			return err
+		}
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			raw := make([]byte, v.Len())
+			reflect.Copy(reflect.ValueOf(raw), v)
+			b = append([]byte{tagBytes}, encodeString(raw)...)
+			break
+		}
+		if _, err := w.Write(append([]byte{tagList}, leftEncode(uint64(v.Len()))...)); err != nil {
+			return err
+		}
+		for i := 0; i < v.Len(); i++ {
+			if err := encodeValue(w, v.Index(i), depth+1); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case reflect.Map:
+		if v.IsNil() {
+			_, err := w.Write([]byte{tagNil})
+			return err
+		}
+		// キーと値をそれぞれエンコードし、キーのバイト列の順に並べる
+		type entry struct{ key, value []byte }
+		entries := make([]entry, 0, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			var k, e bytes.Buffer
+			if err := encodeValue(&k, iter.Key(), depth+1); err != nil {
+				return err
+			}
+			if err := encodeValue(&e, iter.Value(), depth+1); err != nil {
+				return err
+			}
+			entries = append(entries, entry{k.Bytes(), e.Bytes()})
+		}
+		sort.Slice(entries, func(i, j int) bool { return bytes.Compare(entries[i].key, entries[j].key) < 0 })
+
+		if _, err := w.Write(append([]byte{tagMap}, leftEncode(uint64(len(entries)))...)); err != nil {
+			return err
+		}
+		for _, e := range entries {
+			if _, err := w.Write(e.key); err != nil {
+				return err
+			}
+			if _, err := w.Write(e.value); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case reflect.Struct:
+		t := v.Type()
+		var fields []int
+		for i := 0; i < t.NumField(); i++ {
+			if t.Field(i).IsExported() {
+				fields = append(fields, i)
+			}
+		}
+		if _, err := w.Write(append([]byte{tagStruct}, leftEncode(uint64(len(fields)))...)); err != nil {
+			return err
+		}
+		for _, i := range fields {
+			if _, err := w.Write(encodeString([]byte(t.Field(i).Name))); err != nil {
+				return err
+			}
+			if err := encodeValue(w, v.Field(i), depth+1); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	default: // チャネル、関数、unsafe.Pointer
+		return fmt.Errorf("%s 型の値はハッシュできません", v.Type())
+	}
+
+	_, err := w.Write(b)
+	return err
+}
+
+// ParallelHash256 (SP 800-185) をparallelism個のゴルーチンで計算する
+// 入力をblockSizeバイトのブロックに分け、各ブロックのSHAKE256 (512ビット) を並行して求めてから
+// ブロックの順に外側のcSHAKE256へ吸収するので、結果はゴルーチンの実行順や並列数に依存しない
+// 入力はparallelism個のブロックずつ読むため、全体をメモリに読み込む必要はない
+func parallelHash256Reader(r io.Reader, blockSize int, customization []byte, outLen, parallelism int) ([]byte, error) {
+	if blockSize <= 0 || parallelism <= 0 {
+		return nil, fmt.Errorf("ブロック長 %d と並列数 %d は正の値である必要があります", blockSize, parallelism)
+	}
+
+	sp := newCShake256([]byte("ParallelHash"), customization)
+	sp.Write(leftEncode(uint64(blockSize)))
+
+	buf := make([]byte, blockSize*parallelism)
+	results := make([][64]byte, parallelism)
+	var n uint64 // ブロック数
+	for {
+		m, err := io.ReadFull(r, buf)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return nil, err
+		}
+		blocks := (m + blockSize - 1) / blockSize
+
+		var wg sync.WaitGroup
+		for i := 0; i < blocks; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				inner := newShake256()
+				inner.Write(buf[i*blockSize : min((i+1)*blockSize, m)])
+				inner.Squeeze().Read(results[i][:])
+			}()
+		}
+		wg.Wait()
+
+		for i := 0; i < blocks; i++ {
+			sp.Write(results[i][:])
+		}
+		n += uint64(blocks)
+
+		if m < len(buf) {
+			break
+		}
+	}
+
+	sp.Write(rightEncode(n))
+	sp.Write(rightEncode(uint64(outLen) * 8))
+	output := make([]byte, outLen)
+	sp.Squeeze().Read(output)
+	return output, nil
+}
+
+// ParallelHash256 (SP 800-185、outLenバイト出力)。CPUの数だけゴルーチンを使う
+func ParallelHash256(x []byte, blockSize int, customization []byte, outLen int) ([]byte, error) {
+	return parallelHash256Reader(bytes.NewReader(x), blockSize, customization, outLen, runtime.NumCPU())
+}
+
+// スキャナーが返すトークン列をTupleHash256 (32バイト出力) でハッシュする
+// トークンごとに長さを前置して吸収するので、区切り方が違えばダイジェストも変わる
+func SumTokens256(sc *bufio.Scanner) ([]byte, error) {
+	sp := newCShake256([]byte("TupleHash"), nil)
+	for sc.Scan() {
+		tok := sc.Bytes()
+		sp.Write(leftEncode(uint64(len(tok)) * 8))
+		sp.Write(tok)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	sp.Write(rightEncode(32 * 8))
+
+	output := make([]byte, 32)
+	sp.Squeeze().Read(output)
+	return output, nil
+}
+
+// SHAKE256によるマスク生成関数 (seedを吸収してmaskLenバイトを絞り出す)
+func mgfSHAKE256(seed []byte, maskLen int) []byte {
+	sp := newShake256()
+	sp.Write(seed)
+	mask := make([]byte, maskLen)
+	sp.Squeeze().Read(mask)
+	return mask
+}
+
+// MGF1 (RFC 8017) のハッシュ関数にSHA3-256を用いたもの
+// SHA3-256(seed || counter) をカウンタ0から連結し、maskLenバイトに切り詰める
+func mgf1Sha3_256(seed []byte, maskLen int) []byte {
+	mask := make([]byte, 0, maskLen+32)
+	buf := make([]byte, len(seed)+4)
+	copy(buf, seed)
+
+	for counter := uint32(0); len(mask) < maskLen; counter++ {
+		binary.BigEndian.PutUint32(buf[len(seed):], counter)
+		mask = append(mask, sha3_256(buf[:len(buf):len(buf)])...)
+	}
+	return mask[:maskLen]
+}
+
+// SHA3-256ダイジェストの集合 (ゼロ値のまま使用できる)
+// 固定長配列をキーにするので、照合のたびに文字列を割り当てずに済む
+type DigestSet struct {
+	m map[[32]byte]struct{}
+}
+
+// ダイジェストを追加
+func (ds *DigestSet) Add(d [32]byte) {
+	if ds.m == nil {
+		ds.m = make(map[[32]byte]struct{})
+	}
+	ds.m[d] = struct{}{}
+}
+
+// ダイジェストが含まれているか
+func (ds *DigestSet) Contains(d [32]byte) bool {
+	_, ok := ds.m[d]
+	return ok
+}
+
+// スライスで渡されたダイジェストが含まれているか (32バイトでなければfalse)
+func (ds *DigestSet) ContainsBytes(d []byte) bool {
+	if len(d) != 32 {
+		return false
+	}
+	return ds.Contains([32]byte(d))
+}
+
+// 集合の要素数
+func (ds *DigestSet) Len() int {
+	return len(ds.m)
+}
+
+// ダイジェスト列を順に吸収してルートを求めるアキュムレータ
+// 各ダイジェストに長さを前置して吸収するので、結果は tupleHash256(ダイジェスト列, nil, 32) と等しい
+type Accumulator struct {
+	sp *Sponge
+	n  int // 追加したダイジェストの数
+}
+
+func NewAccumulator() *Accumulator {
+	return &Accumulator{sp: newCShake256([]byte("TupleHash"), nil)}
+}
+
+// ダイジェストを1つ追加
+func (a *Accumulator) Add(d [32]byte) {
+	a.sp.Write(leftEncode(32 * 8))
+	a.sp.Write(d[:])
+	a.n++
+}
+
+// 追加したダイジェストの数
+func (a *Accumulator) Len() int { return a.n }
+
+// これまでに追加したダイジェスト列のルート (アキュムレータの状態は変更しない)
+func (a *Accumulator) Root() []byte {
+	sp := a.sp.Clone()
+	sp.Write(rightEncode(32 * 8))
+	root := make([]byte, 32)
+	sp.Squeeze().Read(root)
+	return root
+}
+
+// RFC 6962と同じ方式でドメイン分離したMerkle木のルート
+// 葉は SHA3-256(0x00 || 葉)、内部ノードは SHA3-256(0x01 || 左 || 右) とするので、
+// 葉の区切りをずらした入力や、内部ノードの値を葉として渡した入力とはルートが一致しない
+// 段の要素数が奇数なら、最後の要素は複製せずにそのまま次の段へ繰り上げる
+// (複製すると [a, b, c] と [a, b, c, c] のルートが一致してしまうため)
+// 葉が0個なら空メッセージのSHA3-256を返す
+func MerkleRoot(leaves [][]byte) []byte {
+	if len(leaves) == 0 {
+		return sha3_256(nil)
+	}
+
+	level := make([][]byte, len(leaves))
+	for i, leaf := range leaves {
+		level[i] = sum256Vectored([]byte{0x00}, leaf)
+	}
+	for len(level) > 1 {
+		next := make([][]byte, 0, (len(level)+1)/2)
+		for i := 0; i+1 < len(level); i += 2 {
+			next = append(next, sum256Vectored([]byte{0x01}, level[i], level[i+1]))
+		}
+		if len(level)%2 == 1 {
+			next = append(next, level[len(level)-1])
+		}
+		level = next
+	}
+	return level[0]
+}
+
+// 名前から決定的なUUIDを生成 (UUIDv5と同様の考え方でハッシュにSHAKE256を使用)
+// SHAKE256(namespace || name) の先頭16バイトに、RFC 9562のバージョン8 (独自形式) と
+// バリアント (10xx) のビットを設定する
+func NameUUID(namespace, name []byte) [16]byte {
+	sp := newShake256()
+	sp.Write(namespace)
+	sp.Write(name)
+
+	var u [16]byte
+	sp.Squeeze().Read(u[:])
+	u[6] = (u[6] & 0x0f) | 0x80 // バージョン8
+	u[8] = (u[8] & 0x3f) | 0x80 // バリアント 10xx
+	return u
+}
+
+// HMAC-SHA3-256 (RFC 2104、ブロック長はレートの136バイト) のストリーミング版
+type hmacDigest struct {
+	inner     *Sponge // メッセージを吸収中の内側ハッシュ
+	innerInit *Sponge // ipadを吸収した直後の内側ハッシュ (Reset用)
+	outer     *Sponge // opadを吸収した直後の外側ハッシュ
+}
+
+func newHMAC256(key []byte) *hmacDigest {
+	blockSize := RATE / 8
+	if len(key) > blockSize {
+		key = sha3_256(key)
+	}
+
+	ipad := make([]byte, blockSize)
+	opad := make([]byte, blockSize)
+	copy(ipad, key)
+	copy(opad, key)
+	for i := range ipad {
+		ipad[i] ^= 0x36
+		opad[i] ^= 0x5c
+	}
+
+	d := &hmacDigest{innerInit: newSponge256(), outer: newSponge256()}
+	d.innerInit.Write(ipad)
+	d.outer.Write(opad)
+	d.inner = d.innerInit.Clone()
+	return d
+}
+
+func (d *hmacDigest) Write(p []byte) (int, error) {
+	return d.inner.Write(p)
+}
+
+// 内側ハッシュを確定し、外側ハッシュでopadと内側ダイジェストを吸収した結果をbに追加する
+func (d *hmacDigest) Sum(b []byte) []byte {
+	o := d.outer.Clone()
+	o.Write(d.inner.Sum(nil))
+	return o.Sum(b)
+}
+
+// ipad吸収直後の状態を複製して戻す (鍵の処理はやり直さない)
+func (d *hmacDigest) Reset() {
+	d.inner = d.innerInit.Clone()
+}
+
+func (d *hmacDigest) Size() int { return 32 }
+
+func (d *hmacDigest) BlockSize() int { return RATE / 8 }
+
+// HMAC-SHA3-256 (一括計算)
+func hmacSHA3_256(key, message []byte) []byte {
+	d := newHMAC256(key)
+	d.Write(message)
+	return d.Sum(nil)
+}
+
+// 途中経過の検証点
+type Checkpoint struct {
+	Offset int64  // 先頭からのバイト数
+	Digest []byte // Offsetまでのデータに対するSHA3-256
+}
+
+// チェックポイントでダイジェストが一致しなかったことを表すエラー
+type CheckpointMismatchError struct {
+	Index  int   // 一致しなかったチェックポイントの番号
+	Offset int64 // そのチェックポイントのオフセット
+}
+
+func (e *CheckpointMismatchError) Error() string {
+	return fmt.Sprintf("チェックポイント %d: オフセット %d でダイジェストが一致しません", e.Index, e.Offset)
+}
+
+// リーダーを逐次吸収し、各チェックポイントで途中のダイジェストを照合する
+// 最初に一致しなかった時点で読み込みを中止して*CheckpointMismatchErrorを返す
+func verifyCheckpoints(r io.Reader, checkpoints []Checkpoint) error {
+	sp := newSponge256()
+	var pos int64
+
+	for i, cp := range checkpoints {
+		if cp.Offset < pos {
+			return fmt.Errorf("チェックポイント %d のオフセット %d が昇順ではありません", i, cp.Offset)
+		}
+
+		n, err := io.CopyN(sp, r, cp.Offset-pos)
+		pos += n
+		if err == io.EOF {
+			return fmt.Errorf("チェックポイント %d: オフセット %d に達する前に入力が終了しました (%d バイト)", i, cp.Offset, pos)
+		}
+		if err != nil {
+			return err
+		}
+
+		if !bytes.Equal(sp.Sum(nil), cp.Digest) {
+			return &CheckpointMismatchError{Index: i, Offset: cp.Offset}
+		}
+	}
+
+	return nil
+}
+
+// ビット長を前置したSHA3-256 (left_encode(len(data)*8) || data)
+// 長さもダイジェストに含まれるため、連結の切れ目をずらした入力同士が衝突しない
+func sumPrefixed256(data []byte) []byte {
+	sp := newSponge256()
+	sp.Write(leftEncode(uint64(len(data)) * 8))
+	sp.Write(data)
+	return sp.Sum(nil)
+}
+
+// sumPrefixed256 と同じ値をリーダーからストリーミングで計算する
+// SHA3-256は前置する長さを後から吸収し直せないため、先に全体の長さを知る必要がある
+//   - io.ReadSeekerなら末尾へシークして残りの長さを求め、位置を戻してから1パスで吸収する
+//     (読み込み中に長さが変わった場合はエラー)
+//   - シークできないリーダーは全体をメモリに読み込んでから計算する
+func sumPrefixed256Reader(r io.Reader) ([]byte, error) {
+	rs, ok := r.(io.ReadSeeker)
+	if !ok {
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return nil, err
+		}
+		return sumPrefixed256(data), nil
+	}
+
+	cur, err := rs.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, err
+	}
+	end, err := rs.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := rs.Seek(cur, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	length := end - cur
+	sp := newSponge256()
+	sp.Write(leftEncode(uint64(length) * 8))
+	n, err := io.Copy(sp, rs)
+	if err != nil {
+		return nil, err
+	}
+	if n != length {
+		return nil, fmt.Errorf("読み込み中に入力の長さが変わりました (%d バイトのはずが %d バイト)", length, n)
+	}
+	return sp.Sum(nil), nil
+}
+
+// 二重ハッシュ SHA3-256(SHA3-256(data))
+func DoubleSum256(data []byte) []byte {
+	return sha3_256(sha3_256(data))
+}
+
+// SHA3VS (CAVP) のモンテカルロテスト
+// MD0 = seed から始め、1000回 MD_i = SHA3-256(MD_{i-1}) を繰り返した MD1000 を
+// チェックポイントとして記録し、次の MD0 とする。これをiterations回 (公式には100回) 行う
+func monteCarlo256(seed []byte, iterations int) [][]byte {
+	checkpoints := make([][]byte, 0, iterations)
+	md := seed
+	for j := 0; j < iterations; j++ {
+		for i := 1; i <= 1000; i++ {
+			md = sha3_256(md)
+		}
+		checkpoints = append(checkpoints, md)
+	}
+	return checkpoints
+}
+
+// findReducedCollision で比べる出力の先頭バイト数と、試す入力の上限
+// 16ビットへの切り詰めなら誕生日攻撃で数百個程度の入力で衝突が見つかる
+const reducedCollisionBytes = 2
+const reducedCollisionTries = 1 << 16
+
+// ラウンド数を減らしたSHA3-256の出力を先頭reducedCollisionBytesバイトに切り詰め、
+// 誕生日攻撃で衝突する2つの入力を探す (学習用)
+// 入力は4バイトのビッグエンディアンのカウンタで、reducedCollisionTries個試して
+// 見つからなければokはfalseになる
+func findReducedCollision(rounds int) (a, b []byte, ok bool) {
+	perm := ReducedKeccakP1600(rounds)
+	seen := make(map[[reducedCollisionBytes]byte][]byte)
+
+	for i := uint32(0); i < reducedCollisionTries; i++ {
+		msg := binary.BigEndian.AppendUint32(nil, i)
+		sp := newSponge256()
+		sp.SetPermutation(perm)
+		sp.Write(msg)
+		key := [reducedCollisionBytes]byte(sp.Sum(nil))
+
+		if prev, found := seen[key]; found {
+			return prev, msg, true
+		}
+		seen[key] = msg
+	}
+	return nil, nil, false
+}
+
+// SaltedSum256 が生成するソルトのバイト数
+const saltSize = 16
+
+// ランダムなソルトを生成し、ソルト || SHA3-256(ソルト || data) を返す
+// ソルトは結果と一緒に保存し、VerifySaltedで照合する
+func SaltedSum256(data []byte) (saltAndDigest []byte, err error) {
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	return append(salt, sum256Vectored(salt, data)...), nil
+}
+
+// SaltedSum256 の結果がdataに対するものかを照合する (比較は定数時間)
+func VerifySalted(saltAndDigest, data []byte) bool {
+	if len(saltAndDigest) != saltSize+32 {
+		return false
+	}
+	salt, digest := saltAndDigest[:saltSize], saltAndDigest[saltSize:]
+	return subtle.ConstantTimeCompare(sum256Vectored(salt, data), digest) == 1
+}
+
+// 先頭32バイトの期待するSHA3-256ダイジェストに続くペイロードを読み、一致するかを返す (比較は定数時間)
+// ペイロードはEOFまでストリーミングでハッシュするので、全体をメモリに読み込む必要はない
+// ダイジェストが32バイトに満たない場合や読み込みに失敗した場合はエラーを返す
+func VerifyFramed256(r io.Reader) (bool, error) {
+	want := make([]byte, 32)
+	if _, err := io.ReadFull(r, want); err != nil {
+		return false, fmt.Errorf("先頭の32バイトのダイジェストを読み込めませんでした: %w", err)
+	}
+
+	sp := newSponge256()
+	if _, err := io.Copy(sp, r); err != nil {
+		return false, err
+	}
+	return subtle.ConstantTimeCompare(sp.Sum(nil), want) == 1, nil
+}
+
+// 旧Keccakのパディング (0x01) を使うKeccak-256 (Ethereumのハッシュ関数)
+func keccak256(data []byte) []byte {
+	sp := newSponge256()
+	sp.dsbyte = DomainKeccak
+	sp.Write(data)
+	return sp.Sum(nil)
+}
+
+// 非圧縮の公開鍵 (先頭の0x04を除いた64バイト) からEthereumのアドレスを求める
+// keccak256(公開鍵) の末尾20バイトを、EIP-55のチェックサム付きの大文字小文字混在の16進数で返す
+func ethAddress(pubKey []byte) string {
+	if len(pubKey) != 64 {
+		panic(fmt.Sprintf("ethAddress: 公開鍵は0x04を除いた64バイトである必要があります: %d バイト", len(pubKey)))
+	}
+	addr := []byte(hex.EncodeToString(keccak256(pubKey)[12:]))
+
+	// EIP-55: 小文字の16進数文字列のKeccak-256で、対応するニブルが8以上の英字を大文字にする
+	check := keccak256(addr)
+	for i, c := range addr {
+		nibble := check[i/2] >> 4
+		if i%2 == 1 {
+			nibble = check[i/2] & 0x0f
+		}
+		if c >= 'a' && nibble >= 8 {
+			addr[i] = c - 'a' + 'A'
+		}
+	}
+	return "0x" + string(addr)
+}
+
+// ソルト付きで繰り返しハッシュする簡易ストレッチング H(salt || prev) をiterations回
+// 軽量な総当たり対策であり、Argon2やscryptなどのメモリハードなKDFの代わりにはならない
+func StretchSum256(data, salt []byte, iterations int) []byte {
+	if iterations < 1 {
+		panic("StretchSum256: iterations は1以上である必要があります")
+	}
+
+	prev := data
+	buf := make([]byte, 0, len(salt)+len(data))
+	for i := 0; i < iterations; i++ {
+		buf = append(append(buf[:0], salt...), prev...)
+		prev = sha3_256(buf)
+	}
+	return prev
+}
+
+// ---- FIPS 180-4 SHA-256 (-actual-sha256 用、sha2.go と同じアルゴリズム) ----
+
+// SHA-256で使用する定数
+var sha256K = []uint32{
+	0x428a2f98, 0x71374491, 0xb5c0fbcf, 0xe9b5dba5, 0x3956c25b, 0x59f111f1, 0x923f82a4, 0xab1c5ed5,
+	0xd807aa98, 0x12835b01, 0x243185be, 0x550c7dc3, 0x72be5d74, 0x80deb1fe, 0x9bdc06a7, 0xc19bf174,
+	0xe49b69c1, 0xefbe4786, 0x0fc19dc6, 0x240ca1cc, 0x2de92c6f, 0x4a7484aa, 0x5cb0a9dc, 0x76f988da,
+	0x983e5152, 0xa831c66d, 0xb00327c8, 0xbf597fc7, 0xc6e00bf3, 0xd5a79147, 0x06ca6351, 0x14292967,
+	0x27b70a85, 0x2e1b2138, 0x4d2c6dfc, 0x53380d13, 0x650a7354, 0x766a0abb, 0x81c2c92e, 0x92722c85,
+	0xa2bfe8a1, 0xa81a664b, 0xc24b8b70, 0xc76c51a3, 0xd192e819, 0xd6990624, 0xf40e3585, 0x106aa070,
+	0x19a4c116, 0x1e376c08, 0x2748774c, 0x34b0bcb5, 0x391c0cb3, 0x4ed8aa4a, 0x5b9cca4f, 0x682e6ff3,
+	0x748f82ee, 0x78a5636f, 0x84c87814, 0x8cc70208, 0x90befffa, 0xa4506ceb, 0xbef9a3f7, 0xc67178f2,
+}
+
+// SHA-256の初期ハッシュ値
+var sha256H = []uint32{
+	0x6a09e667, 0xbb67ae85, 0x3c6ef372, 0xa54ff53a,
+	0x510e527f, 0x9b05688c, 0x1f83d9ab, 0x5be0cd19,
+}
+
+// 32ビット右ローテーション
+func rotr32(x uint32, n uint32) uint32 {
+	return (x >> n) | (x << (32 - n))
+}
+
+// SHA-256のパディング (0x80、ゼロ、64ビットのビット長)
+// tailは未処理の端数、lengthはメッセージ全体のバイト数
+func sha256Pad(tail []byte, length uint64) []byte {
+	paddedLength := (len(tail) + 1 + 8 + 63) / 64 * 64
+	paddedMessage := make([]byte, paddedLength)
+
+	copy(paddedMessage, tail)
+	paddedMessage[len(tail)] = 0x80
+	binary.BigEndian.PutUint64(paddedMessage[paddedLength-8:], length*8)
+
+	return paddedMessage
+}
+
+// SHA-256の圧縮関数 (1ブロック分)
+func sha256Block(state *[8]uint32, chunk []byte) {
+	// メッセージスケジュール
+	var w [64]uint32
+	for i := 0; i < 16; i++ {
+		w[i] = binary.BigEndian.Uint32(chunk[i*4 : (i+1)*4])
+	}
+	for i := 16; i < 64; i++ {
+		s0 := rotr32(w[i-15], 7) ^ rotr32(w[i-15], 18) ^ (w[i-15] >> 3)
+		s1 := rotr32(w[i-2], 17) ^ rotr32(w[i-2], 19) ^ (w[i-2] >> 10)
+		w[i] = w[i-16] + s0 + w[i-7] + s1
+	}
+
+	// 作業変数の初期化
+	a, b, c, d, e, f, g, h := state[0], state[1], state[2], state[3], state[4], state[5], state[6], state[7]
+
+	for t := 0; t < 64; t++ {
+		S1 := rotr32(e, 6) ^ rotr32(e, 11) ^ rotr32(e, 25)
+		ch := (e & f) ^ ((^e) & g)
+		temp1 := h + S1 + ch + sha256K[t] + w[t]
+		S0 := rotr32(a, 2) ^ rotr32(a, 13) ^ rotr32(a, 22)
+		maj := (a & b) ^ (a & c) ^ (b & c)
+		temp2 := S0 + maj
+
+		h = g
+		g = f
+		f = e
+		e = d + temp1
+		d = c
+		c = b
+		b = a
+		a = temp1 + temp2
+	}
+
+	// 状態の更新
+	state[0] += a
+	state[1] += b
+	state[2] += c
+	state[3] += d
+	state[4] += e
+	state[5] += f
+	state[6] += g
+	state[7] += h
+}
+
+// ストリーミング処理用のSHA-256
+type sha256Digest struct {
+	h   [8]uint32
+	buf []byte // 64バイト未満の端数
+	len uint64 // これまでに書き込まれたバイト数
+}
+
+func newSHA256() *sha256Digest {
+	d := new(sha256Digest)
+	d.Reset()
+	return d
+}
+
+func (d *sha256Digest) Reset() {
+	copy(d.h[:], sha256H)
+	d.buf = d.buf[:0]
+	d.len = 0
+}
+
+func (d *sha256Digest) Write(p []byte) (int, error) {
+	d.len += uint64(len(p))
+	d.buf = append(d.buf, p...)
+
+	// 512ビット（64バイト）ごとに処理
+	i := 0
+	for ; i+64 <= len(d.buf); i += 64 {
+		sha256Block(&d.h, d.buf[i:i+64])
+	}
+	d.buf = append(d.buf[:0], d.buf[i:]...)
+	return len(p), nil
+}
+
+func (d *sha256Digest) Sum(b []byte) []byte {
+	state := d.h
+	paddedTail := sha256Pad(d.buf, d.len)
+	for i := 0; i < len(paddedTail); i += 64 {
+		sha256Block(&state, paddedTail[i:i+64])
+	}
+
+	hash := make([]byte, 32)
+	for i := 0; i < 8; i++ {
+		binary.BigEndian.PutUint32(hash[i*4:(i+1)*4], state[i])
+	}
+	return append(b, hash...)
+}
+
+func (d *sha256Digest) Size() int { return 32 }
+
+func (d *sha256Digest) BlockSize() int { return 64 }
+
+// SHA-256のメイン処理
+func sha256Sum(message []byte) []byte {
+	d := newSHA256()
+	d.Write(message)
+	return d.Sum(nil)
+}
+
+// 25レーンをFIPS 202のレーン番号順 (x + 5y) に16進数で出力
+func dumpLanes(w io.Writer, s *state) {
+	for i := 0; i < 25; i++ {
+		fmt.Fprintf(w, "レーン %2d (x=%d, y=%d): %016x\n", i, i%5, i/5, s.a[i%5][i/5])
+	}
+}
+
+// スポンジのレートとドメイン分離バイトでパディングしたメッセージを16進ダンプで出力 (置換の前の状態)
+func dumpPadded(w io.Writer, sp *Sponge, message []byte) {
+	padded := append(append([]byte(nil), message...), Pad101(len(message)*8, sp.rate*8, sp.dsbyte)...)
+	fmt.Fprintf(w, "パディング後のメッセージ (%d バイト、レート %d バイト):\n", len(padded), sp.rate)
+	fmt.Fprint(w, hex.Dump(padded))
+}
+
+// SHA-256のmultihashコード (SHA-3系はvariantsに記載)
+const sha256MultihashCode = 0x12
+
+// multihash形式 (varintのアルゴリズムコード、varintのダイジェスト長、ダイジェスト)
+func multihash(code uint64, digest []byte) []byte {
+	b := binary.AppendUvarint(nil, code)
+	b = binary.AppendUvarint(b, uint64(len(digest)))
+	return append(b, digest...)
+}
+
+// ダイジェストをビッグエンディアンの符号なし整数として10進数にする
+// (先頭のゼロのバイトは値に影響しないため、元に戻すときは出力長に合わせて左をゼロで埋める)
+func decimalString(digest []byte) string {
+	return new(big.Int).SetBytes(digest).String()
+}
+
+// Base58 (Bitcoin/IPFSの文字セット)
+const base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+// Base58エンコード (先頭のゼロバイトは'1'になる)
+func base58Encode(b []byte) string {
+	zeros := 0
+	for zeros < len(b) && b[zeros] == 0 {
+		zeros++
+	}
+
+	// 58進数の各桁 (下位から)
+	var digits []byte
+	for _, c := range b[zeros:] {
+		carry := int(c)
+		for i := range digits {
+			carry += int(digits[i]) << 8
+			digits[i] = byte(carry % 58)
+			carry /= 58
+		}
+		for carry > 0 {
+			digits = append(digits, byte(carry%58))
+			carry /= 58
+		}
+	}
+
+	out := make([]byte, zeros+len(digits))
+	for i := 0; i < zeros; i++ {
+		out[i] = base58Alphabet[0]
+	}
+	for i, d := range digits {
+		out[len(out)-1-i] = base58Alphabet[d]
+	}
+	return string(out)
+}
+
+// 文字列をn文字ごとに空白で区切る
+func groupString(s string, n int) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i += n {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(s[i:min(i+n, len(s))])
+	}
+	return b.String()
+}
+
+// 改行やバックスラッシュを含む名前をエスケープする (エスケープした場合はtrue)
+func escapeName(name string) (string, bool) {
+	if !strings.ContainsAny(name, "\\\n") {
+		return name, false
+	}
+	return strings.NewReplacer("\\", "\\\\", "\n", "\\n").Replace(name), true
+}
+
+// sha3sum形式の1行を出力 (エスケープした名前は行頭にバックスラッシュを付ける)
+func writeSumLine(w io.Writer, digest string, name string) {
+	prefix := ""
+	name, escaped := escapeName(name)
+	if escaped {
+		prefix = "\\"
+	}
+	fmt.Fprintf(w, "%s%s  %s\n", prefix, digest, name)
+}
+
+// 照合結果の1行を出力 ("名前: 結果"、エスケープした名前は行頭にバックスラッシュを付ける)
+func writeCheckLine(w io.Writer, name, result string) {
+	prefix := ""
+	name, escaped := escapeName(name)
+	if escaped {
+		prefix = "\\"
+	}
+	fmt.Fprintf(w, "%s%s: %s\n", prefix, name, result)
+}
+
+// ダイジェストの表示設定
+type formatOpts struct {
+	encode  func([]byte) string // 16進数、multihash、区切り付きなどの表示形式
+	sumLine bool                // sha3sum形式の1行にする (falseなら対話モードの表示)
+}
+
+// 入力とダイジェストを表示用の文字列にする (algoは対話モードの表示名)
+func formatResult(algo, input string, digest []byte, opts formatOpts) string {
+	var b strings.Builder
+	if opts.sumLine {
+		writeSumLine(&b, opts.encode(digest), input)
+		return b.String()
+	}
+	fmt.Fprintf(&b, "\n入力文字列: %s\n", input)
+	fmt.Fprintf(&b, "%sハッシュ値: %s\n", algo, opts.encode(digest))
+	return b.String()
+}
+
+// 同じディレクトリの一時ファイルに書き込み、成功した場合のみ目的のファイルに置き換える
+// 途中で失敗・強制終了しても、目的のファイルが書きかけの状態になることはない
+func writeFileAtomic(path string, write func(io.Writer) error) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // 置き換え後は存在しないので何もしない
+
+	if err := write(tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(0o644); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// -stats で集計する入力サイズの統計 (バイト単位)
+type inputStats struct {
+	count    int64
+	total    int64
+	min, max int64
+}
+
+// 1件分の入力サイズを加える
+func (st *inputStats) add(n int64) {
+	if st.count == 0 || n < st.min {
+		st.min = n
+	}
+	if n > st.max {
+		st.max = n
+	}
+	st.count++
+	st.total += n
+}
+
+// 集計結果を出力する
+func (st *inputStats) write(w io.Writer) {
+	if st.count == 0 {
+		fmt.Fprintln(w, "統計: ハッシュした入力はありません")
+		return
+	}
+	fmt.Fprintf(w, "統計: 入力 %d 件、合計 %d バイト、最小 %d、最大 %d、平均 %.1f バイト\n",
+		st.count, st.total, st.min, st.max, float64(st.total)/float64(st.count))
+}
+
+// 進捗表示を更新する最短の間隔
+const progressInterval = 100 * time.Millisecond
+
+// 標準エラーに1行で上書きしていく進捗表示 (処理したファイル数/総数と累計バイト数)
+type progress struct {
+	w     io.Writer
+	total int       // ファイルの総数
+	done  int       // 処理したファイル数
+	bytes int64     // 累計バイト数
+	last  time.Time // 最後に表示した時刻
+	shown bool      // 表示中の行があるか
+}
+
+// 標準エラーが端末ならtotal個のファイルの進捗表示を返す (端末でなければnil)
+func newProgress(total int) *progress {
+	fi, err := os.Stderr.Stat()
+	if err != nil || fi.Mode()&os.ModeCharDevice == 0 {
+		return nil
+	}
+	return &progress{w: os.Stderr, total: total}
+}
+
+// 1ファイル分を進め、前回の表示から一定時間が経っていれば表示を更新する
+func (p *progress) step() {
+	p.done++
+	if p.done < p.total && time.Since(p.last) < progressInterval {
+		return
+	}
+	p.last = time.Now()
+	p.shown = true
+	fmt.Fprintf(p.w, "\r\033[K%d/%d ファイル、%d バイト", p.done, p.total, p.bytes)
+}
+
+// 表示中の行を消す (結果やエラーの出力前と終了時に呼ぶ)
+func (p *progress) clear() {
+	if p.shown {
+		fmt.Fprint(p.w, "\r\033[K")
+		p.shown = false
+	}
+}
+
+// キャッシュファイルの1行目 (形式の版とダイジェストの計算条件)
+const digestCacheVersion = "sha3-cache 1"
+
+// キャッシュの1エントリ
+type cacheEntry struct {
+	size   int64
+	mtime  int64 // 更新時刻 (UnixNano)
+	digest []byte
+}
+
+// ファイルのダイジェストのキャッシュ (キーは絶対パス)
+// サイズか更新時刻が記録と違うファイルは変更されたものとみなして再計算する
+// 計算条件 (アルゴリズムや -head など) が違うキャッシュは丸ごと使わない
+//
+// ファイルの形式 (テキスト):
+//
+//	sha3-cache 1 <計算条件>
+//	<サイズ> <更新時刻> <ダイジェストの16進数> <パス (writeSumLineと同じエスケープ)>
+type digestCache struct {
+	config  string
+	entries map[string]cacheEntry
+	dirty   bool // 読み込み後に変更があったか
+}
+
+// キャッシュファイルを読み込む (存在しなければ空のキャッシュ)
+// 形式が壊れている場合は、空のキャッシュとエラーを返す (呼び出し側は警告して続行できる)
+func loadDigestCache(path, config string) (*digestCache, error) {
+	c := &digestCache{config: config, entries: make(map[string]cacheEntry)}
+
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return c, nil
+	}
+	if err != nil {
+		return c, err
+	}
+	defer f.Close()
+
+	sc := bufio.NewScanner(f)
+	if !sc.Scan() || sc.Text() != digestCacheVersion+" "+config {
+		// 版か計算条件が違う (保存時に置き換わる)
+		c.dirty = true
+		return c, sc.Err()
+	}
+	for lineNo := 2; sc.Scan(); lineNo++ {
+		fields := strings.SplitN(sc.Text(), " ", 4)
+		if len(fields) != 4 {
+			return &digestCache{config: config, entries: make(map[string]cacheEntry), dirty: true},
+				fmt.Errorf("%s: %d 行目の形式が不正です", path, lineNo)
+		}
+		size, err1 := strconv.ParseInt(fields[0], 10, 64)
+		mtime, err2 := strconv.ParseInt(fields[1], 10, 64)
+		digest, err3 := hex.DecodeString(fields[2])
+		if err1 != nil || err2 != nil || err3 != nil {
+			return &digestCache{config: config, entries: make(map[string]cacheEntry), dirty: true},
+				fmt.Errorf("%s: %d 行目の形式が不正です", path, lineNo)
+		}
+		c.entries[unescapeName(fields[3])] = cacheEntry{size: size, mtime: mtime, digest: digest}
+	}
+	return c, sc.Err()
+}
+
+// キャッシュのキー (絶対パス)
+func cacheKey(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return filepath.Clean(path)
+}
+
+// サイズと更新時刻が記録と同じならキャッシュしたダイジェストを返す
+func (c *digestCache) lookup(path string, fi os.FileInfo) ([]byte, bool) {
+	e, ok := c.entries[cacheKey(path)]
+	if !ok || e.size != fi.Size() || e.mtime != fi.ModTime().UnixNano() {
+		return nil, false
+	}
+	return e.digest, true
+}
+
+// ダイジェストを記録する
+func (c *digestCache) store(path string, fi os.FileInfo, digest []byte) {
+	c.entries[cacheKey(path)] = cacheEntry{size: fi.Size(), mtime: fi.ModTime().UnixNano(), digest: digest}
+	c.dirty = true
+}
+
+// 変更があればキャッシュファイルを書き出す (パスの順に並べ、一時ファイル経由で置き換える)
+func (c *digestCache) save(path string) error {
+	if !c.dirty {
+		return nil
+	}
+	keys := make([]string, 0, len(c.entries))
+	for k := range c.entries {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return writeFileAtomic(path, func(w io.Writer) error {
+		bw := bufio.NewWriter(w)
+		fmt.Fprintf(bw, "%s %s\n", digestCacheVersion, c.config)
+		for _, k := range keys {
+			e := c.entries[k]
+			name, _ := escapeName(k)
+			fmt.Fprintf(bw, "%d %d %x %s\n", e.size, e.mtime, e.digest, name)
+		}
+		return bw.Flush()
+	})
+}
+
+// ファイルをハッシュしてsha3sum形式で出力する際の設定
+type fileHasher struct {
+	newHash func() hash.Hash
+	encode  func([]byte) string
+	head    int64 // 正の値なら各ファイルの先頭headバイトのみをハッシュする
+
+	// ファイル名 (パスの最後の要素) とサイズも含めてSumWithMeta256でハッシュする
+	// newHashの指定によらずTupleHash256になり、内容だけのダイジェストとは一致しない
+	withMeta bool
+
+	stats *inputStats // nilでなければハッシュした入力のサイズを集計する
+
+	gunzip     bool // gzipを展開しながら、展開後の内容をハッシュする
+	decompress bool // 先頭のマジックバイトで圧縮形式を判定し、圧縮されていれば展開する
+
+	progress *progress // nilでなければhashFilesの進捗を表示する
+
+	cache *digestCache // nilでなければ変更のないファイルのダイジェストを再利用する
+
+	// 正の値ならnewHashの代わりに、このブロック長のParallelHash256 (32バイト出力) で
+	// parallelism個のゴルーチンを使ってハッシュする
+	parallelBlock int
+	parallelism   int
+
+	// 大きなファイル (autotuneMinSize以上) のハッシュ前に読み込みバッファの長さを計測して選ぶ
+	autotune bool
+
+	json   bool  // writeSumでsha3sum形式の代わりに1ファイル1行のJSONを出力する
+	timing bool  // JSONにハッシュの所要時間とスループットを含める
+	hashed int64 // これまでにハッシュしたバイト数 (キャッシュから返したファイルは含まない)
+}
+
+// 圧縮形式を判定するマジックバイト
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// ハッシュする内容を読むリーダー (-gunzip、-decompress なら展開し、-head ならその長さで打ち切る)
+// nameはエラーメッセージに使う入力の名前
+func (fh *fileHasher) content(r io.Reader, name string) (io.Reader, error) {
+	gz := fh.gunzip
+	if fh.decompress {
+		br := bufio.NewReader(r)
+		magic, _ := br.Peek(len(zstdMagic))
+		switch {
+		case bytes.HasPrefix(magic, gzipMagic):
+			gz = true
+		case bytes.HasPrefix(magic, zstdMagic):
+			// 標準ライブラリにzstdの展開器がないため未対応
+			return nil, fmt.Errorf("%s: zstd形式の展開には対応していません", name)
+		}
+		r = br
+	}
+
+	if gz {
+		zr, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("%s: gzipの展開に失敗しました: %w", name, err)
+		}
+		r = &gunzipReader{zr: zr, name: name}
+	}
+	if fh.head > 0 {
+		r = io.LimitReader(r, fh.head)
+	}
+	return r, nil
+}
+
+// 展開中のエラーに、gzipの展開で起きたことが分かるよう説明を付けるリーダー
+// (途中で切れたgzipはio.ErrUnexpectedEOF、壊れたものはgzip.ErrChecksumなどになる)
+type gunzipReader struct {
+	zr   *gzip.Reader
+	name string
+}
+
+func (g *gunzipReader) Read(p []byte) (int, error) {
+	n, err := g.zr.Read(p)
+	if err != nil && err != io.EOF {
+		err = fmt.Errorf("%s: gzipの展開に失敗しました: %w", g.name, err)
+	}
+	return n, err
+}
+
+// ハッシュした入力のサイズを統計に加える (-stats 指定時のみ)
+func (fh *fileHasher) record(n int64) {
+	fh.hashed += n
+	if fh.stats != nil {
+		fh.stats.add(n)
+	}
+	if fh.progress != nil {
+		fh.progress.bytes += n
+	}
+}
+
+// ファイルのダイジェスト (キャッシュがあり、パス・サイズ・更新時刻が前回と同じなら読み込まない)
+// (-head 指定時はハッシュしたバイト数を表示に使うため、キャッシュを使わずに毎回読む。run も
+// -head 指定時はキャッシュを読み込まない)
+func (fh *fileHasher) hashFile(path string) ([]byte, error) {
+	if fh.cache == nil || fh.head > 0 {
+		return fh.readAndHash(path)
+	}
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if digest, ok := fh.cache.lookup(path, fi); ok {
+		return digest, nil
+	}
+	digest, err := fh.readAndHash(path)
+	if err != nil {
+		return nil, err
+	}
+	fh.cache.store(path, fi, digest)
+	return digest, nil
+}
+
+// ファイルの内容をストリーミングでハッシュ (-head 指定時はその位置で読み込みを止める)
+func (fh *fileHasher) readAndHash(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r, err := fh.content(f, path)
+	if err != nil {
+		return nil, err
+	}
+
+	if fh.withMeta {
+		fi, err := f.Stat()
+		if err != nil {
+			return nil, err
+		}
+		size := fi.Size()
+		if fh.head > 0 {
+			size = min(size, fh.head)
+		}
+		fh.record(size)
+		return SumWithMeta256(filepath.Base(path), size, r)
+	}
+
+	if fh.parallelBlock > 0 {
+		cr := &countingReader{r: r}
+		digest, err := parallelHash256Reader(cr, fh.parallelBlock, nil, 32, fh.parallelism)
+		if err != nil {
+			return nil, err
+		}
+		fh.record(cr.n)
+		return digest, nil
+	}
+
+	h := fh.newHash()
+	var n int64
+	if fh.autotune {
+		if fi, err := f.Stat(); err == nil && fi.Mode().IsRegular() && fi.Size() >= autotuneMinSize {
+			n, err = copyBuffered(h, r, autotuneBufSize(f, fi.Size(), fh.newHash))
+			if err != nil {
+				return nil, err
+			}
+			fh.record(n)
+			return h.Sum(nil), nil
+		}
+	}
+	n, err = io.Copy(h, r)
+	if err != nil {
+		return nil, err
+	}
+	fh.record(n)
+	return h.Sum(nil), nil
+}
+
+// -autotune で計測する読み込みバッファの長さの候補
+var autotuneCandidates = []int{16 << 10, 64 << 10, 256 << 10, 1 << 20}
+
+var (
+	autotuneMinSize int64 = 64 << 20 // これより小さいファイルは計測せずio.Copyの既定のバッファで読む
+	autotuneSample  int64 = 8 << 20  // 各候補で読み込んでハッシュするファイル先頭のバイト数
+)
+
+// ファイル先頭のautotuneSampleバイトを各候補のバッファ長で読み込んでハッシュし、最も速かった長さを返す
+// 最初に一度読んでおき、ページキャッシュの有無で最初の候補だけが不利にならないようにする
+// 選んだ長さは読み込みの単位を変えるだけで、ダイジェストには影響しない
+func autotuneBufSize(f io.ReaderAt, size int64, newHash func() hash.Hash) int {
+	sample := min(size, autotuneSample)
+	io.Copy(io.Discard, io.NewSectionReader(f, 0, sample))
+
+	best, bestTime := autotuneCandidates[0], time.Duration(math.MaxInt64)
+	for _, c := range autotuneCandidates {
+		start := time.Now()
+		if _, err := copyBuffered(newHash(), io.NewSectionReader(f, 0, sample), c); err != nil {
+			continue
+		}
+		if d := time.Since(start); d < bestTime {
+			best, bestTime = c, d
+		}
+	}
+	return best
+}
+
+// bufSizeバイトのバッファでsrcからdstへコピーする
+// (io.CopyBufferはReaderFrom/WriterToがあるとバッファを使わないため、それらを隠して呼ぶ)
+func copyBuffered(dst io.Writer, src io.Reader, bufSize int) (int64, error) {
+	return io.CopyBuffer(struct{ io.Writer }{dst}, struct{ io.Reader }{src}, make([]byte, bufSize))
+}
+
+// srcをdstへそのままコピーしながらハッシュし、コピーしたバイト数とダイジェストを返す
+func hashAndCopy(dst io.Writer, src io.Reader, h hash.Hash) (int64, []byte, error) {
+	n, err := io.Copy(io.MultiWriter(dst, h), src)
+	if err != nil {
+		return n, nil, err
+	}
+	return n, h.Sum(nil), nil
+}
+
+// 1回の書き込みを複数のハッシュ関数へ同時に渡す (同じ入力を一度だけ読んで複数のダイジェストを求める)
+type MultiHasher struct {
+	hashes []hash.Hash
+}
+
+// 指定したハッシュ関数に書き込むMultiHasherを生成
+func NewMultiHasher(hashes ...hash.Hash) *MultiHasher {
+	return &MultiHasher{hashes: hashes}
+}
+
+func (m *MultiHasher) Write(p []byte) (int, error) {
+	for _, h := range m.hashes {
+		h.Write(p)
+	}
+	return len(p), nil
+}
+
+// 各ハッシュ関数のダイジェスト (NewMultiHasherに渡した順)
+func (m *MultiHasher) Sums() [][]byte {
+	sums := make([][]byte, len(m.hashes))
+	for i, h := range m.hashes {
+		sums[i] = h.Sum(nil)
+	}
+	return sums
+}
+
+// 1ファイルを一度だけ読んで複数のアルゴリズムでハッシュし、
+// "アルゴリズム (名前) = ダイジェスト" (BSD形式) の行をアルゴリズムごとに出力する
+// 失敗時は標準エラーに出力してfalseを返す
+func (fh *fileHasher) writeMultiSum(w io.Writer, name string, algs []cliAlgorithm) bool {
+	before := fh.hashed
+	sums, err := fh.hashMulti(name, algs)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "エラー:", err)
+		return false
+	}
+	for i, alg := range algs {
+		fmt.Fprintf(w, "%s (%s) = %s\n", alg.name, name, fh.label(fh.encode(sums[i]), fh.hashed-before))
+	}
+	return true
+}
+
+// ファイルの内容をalgsの各アルゴリズムでハッシュする
+func (fh *fileHasher) hashMulti(path string, algs []cliAlgorithm) ([][]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r, err := fh.content(f, path)
+	if err != nil {
+		return nil, err
+	}
+
+	hashes := make([]hash.Hash, len(algs))
+	for i, alg := range algs {
+		hashes[i] = alg.newHash()
+	}
+	m := NewMultiHasher(hashes...)
+	n, err := io.Copy(m, r)
+	if err != nil {
+		return nil, err
+	}
+	fh.record(n)
+	return m.Sums(), nil
+}
+
+// -algorithms のカンマ区切りの一覧を解釈する
+// 各要素は -algorithm と同じ形式か、keccak-224〜keccak-512 (旧Keccakのパディング)、sha256 (SHA-256)
+func parseAlgorithmList(list string) ([]cliAlgorithm, error) {
+	var algs []cliAlgorithm
+	for _, spec := range strings.Split(list, ",") {
+		spec = strings.ToLower(strings.TrimSpace(spec))
+		var alg cliAlgorithm
+		var err error
+		switch {
+		case spec == "sha256" || spec == "sha-256":
+			alg = sha256Algorithm()
+		case strings.HasPrefix(spec, "keccak-"):
+			alg, err = selectAlgorithm("sha3-"+strings.TrimPrefix(spec, "keccak-"), "keccak", false)
+		default:
+			alg, err = selectAlgorithm(spec, "sha3", false)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("-algorithms の %q: %w", spec, err)
+		}
+		algs = append(algs, alg)
+	}
+	return algs, nil
+}
+
+// 読み込んだバイト数を数えるリーダー
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// 2つのファイルの内容が同じかをダイジェストで比べる
+// 比べる長さ (-head 指定時は先頭headバイト) が違えばハッシュせずにfalseを返す
+// (-gunzip、-decompress 指定時は展開後の長さが分からないので、常に両方をハッシュする)
+func (fh *fileHasher) sameContent(a, b string) (bool, error) {
+	if !fh.gunzip && !fh.decompress {
+		fa, err := os.Stat(a)
+		if err != nil {
+			return false, err
+		}
+		fb, err := os.Stat(b)
+		if err != nil {
+			return false, err
+		}
+		sa, sb := fa.Size(), fb.Size()
+		if fh.head > 0 {
+			sa, sb = min(sa, fh.head), min(sb, fh.head)
+		}
+		if sa != sb {
+			return false, nil
+		}
+	}
+
+	da, err := fh.hashFile(a)
+	if err != nil {
+		return false, err
+	}
+	db, err := fh.hashFile(b)
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(da, db), nil
+}
+
+// URLの内容をダウンロードしながらハッシュ (レスポンス本体はメモリに溜めない)
+// 200以外のステータスはエラーとして扱う
+func (fh *fileHasher) hashURL(client *http.Client, url string) ([]byte, error) {
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: %s", url, resp.Status)
+	}
+
+	r, err := fh.content(resp.Body, url)
+	if err != nil {
+		return nil, err
+	}
+
+	h := fh.newHash()
+	n, err := io.Copy(h, r)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", url, err)
+	}
+	fh.record(n)
+	return h.Sum(nil), nil
+}
+
+// 1ファイルをハッシュしてsha3sum形式で出力 (失敗時は標準エラーに出力してfalseを返す)
+func (fh *fileHasher) writeSum(w io.Writer, name string) bool {
+	start, before := time.Now(), fh.hashed
+	digest, err := fh.hashFile(name)
+	elapsed := time.Since(start)
+
+	// 標準出力も同じ端末の場合に備え、進捗の行を消してから出力する
+	if fh.progress != nil {
+		fh.progress.clear()
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "エラー:", err)
+		return false
+	}
+	if !fh.json {
+		writeSumLine(w, fh.label(fh.encode(digest), fh.hashed-before), name)
+		return true
+	}
+
+	res := jsonResult{File: name, Digest: fh.encode(digest)}
+	if fh.head > 0 {
+		n := fh.hashed - before
+		res.HeadBytes = &n
+	}
+	if fh.timing {
+		res.setTiming(fh.hashed-before, elapsed)
+	}
+	if err := json.NewEncoder(w).Encode(res); err != nil {
+		fmt.Fprintln(os.Stderr, "エラー:", err)
+		return false
+	}
+	return true
+}
+
+// -head 指定時は内容全体のダイジェストではないことが分かるよう、実際にハッシュしたバイト数nを
+// "head:n:" として前に付ける (headより短いファイルは全体をハッシュするので、nはファイルの長さになる)
+func (fh *fileHasher) label(digest string, n int64) string {
+	if fh.head <= 0 {
+		return digest
+	}
+	return fmt.Sprintf("%s%d:%s", headLabelPrefix, n, digest)
+}
+
+// -head の出力でダイジェストの前に付けるラベルの接頭辞
+const headLabelPrefix = "head:"
+
+// -json で出力する1ファイル分の結果
+type jsonResult struct {
+	File   string `json:"file"`
+	Digest string `json:"digest"`
+
+	HeadBytes *int64 `json:"head_bytes,omitempty"` // -head 指定時のみ、実際にハッシュした先頭のバイト数
+
+	// -timing 指定時のみ (ハッシュの呼び出しだけを計測し、キャッシュから返した場合はバイト数0)
+	DurationNS     *int64   `json:"duration_ns,omitempty"`
+	ThroughputMBps *float64 `json:"throughput_mbps,omitempty"`
+}
+
+// nバイトのハッシュにdかかったときの所要時間とスループット (MB/s、1MB = 10^6バイト) を設定する
+func (r *jsonResult) setTiming(n int64, d time.Duration) {
+	ns := d.Nanoseconds()
+	mbps := 0.0
+	if d > 0 {
+		mbps = float64(n) / d.Seconds() / 1e6
+	}
+	r.DurationNS, r.ThroughputMBps = &ns, &mbps
+}
+
+// CSVの1行をフィールド単位でハッシュ (TupleHash256、32バイト出力)
+// フィールドごとに長さを前置するので "a,bc" と "ab,c" は別のダイジェストになる
+func SumCSVRow(fields []string) []byte {
+	tuple := make([][]byte, len(fields))
+	for i, f := range fields {
+		tuple[i] = []byte(f)
+	}
+	return tupleHash256(tuple, nil, 32)
+}
+
+// CSVを1行ずつ解析し、各行のダイジェストを "名前:行番号" の名前でsha3sum形式で出力する
+// 引用符で囲まれたフィールドや埋め込まれたカンマ・改行はencoding/csvが解釈する
+func (fh *fileHasher) hashCSV(r io.Reader, w io.Writer, name string) error {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1 // 行ごとにフィールド数が違ってもよい
+	cr.ReuseRecord = true
+	for {
+		fields, err := cr.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		line, _ := cr.FieldPos(0)
+		writeSumLine(w, fh.encode(SumCSVRow(fields)), fmt.Sprintf("%s:%d", name, line))
+	}
+}
+
+// 文字列をハッシュしてsha3sum形式で出力 (名前の欄には文字列そのものを出力する)
+// joinがtrueなら全体をsepで連結した1つの文字列として、falseなら1つずつハッシュする
+func (fh *fileHasher) hashStrings(w io.Writer, args []string, join bool, sep string) {
+	if join {
+		args = []string{strings.Join(args, sep)}
+	}
+	for _, arg := range args {
+		h := fh.newHash()
+		io.WriteString(h, arg)
+		fh.record(int64(len(arg)))
+		io.WriteString(w, formatResult("", arg, h.Sum(nil), formatOpts{encode: fh.encode, sumLine: true}))
+	}
+}
+
+// 入力をchunkSizeバイトごとに区切って各チャンクのダイジェストを1行ずつ出力し、
+// 最後の行にチャンクのダイジェストを連結したものに対するダイジェスト (ルート) を出力する
+// 最後のチャンクはchunkSizeより短くてもよい (空の入力ならルートの行だけになる)
+func (fh *fileHasher) hashChunked(r io.Reader, w io.Writer, chunkSize int64) error {
+	root := fh.newHash()
+	for {
+		h := fh.newHash()
+		n, err := io.CopyN(h, r, chunkSize)
+		if err != nil && err != io.EOF {
+			return err
+		}
+		if n > 0 {
+			digest := h.Sum(nil)
+			root.Write(digest)
+			fmt.Fprintln(w, fh.encode(digest))
+		}
+		if err == io.EOF {
+			break
+		}
+	}
+	fmt.Fprintln(w, fh.encode(root.Sum(nil)))
+	return nil
+}
+
+// 追記され続けるファイルを tail -f のように読み続け、増えるたびにそれまでの全体のダイジェストを出力する
+// 途中のダイジェストはSum (複製に対して確定する) で求めるので、吸収中の状態はそのまま続けられる
+// interval ごとに末尾を確認し、idle の間ファイルが増えなければ終了する (0なら終了しない)
+func (fh *fileHasher) follow(r io.Reader, w io.Writer, name string, interval, idle time.Duration) error {
+	h := fh.newHash()
+	var total int64
+	lastGrowth := time.Now()
+	for first := true; ; first = false {
+		n, err := io.Copy(h, r)
+		if err != nil {
+			return err
+		}
+		total += n
+
+		switch {
+		case n > 0 || first:
+			lastGrowth = time.Now()
+			writeSumLine(w, fh.encode(h.Sum(nil)), fmt.Sprintf("%s (%d バイト)", name, total))
+		case idle > 0 && time.Since(lastGrowth) >= idle:
+			return nil
+		}
+		time.Sleep(interval)
+	}
+}
+
+// 複数のファイルを順にハッシュ (失敗したファイルがあればfalseを返す)
+func (fh *fileHasher) hashFiles(w io.Writer, names []string) bool {
+	if fh.progress != nil {
+		fh.progress.total = len(names)
+		defer fh.progress.clear()
+	}
+
+	ok := true
+	for _, name := range names {
+		if !fh.writeSum(w, name) {
+			ok = false
+		}
+		if fh.progress != nil {
+			fh.progress.step()
+		}
+	}
+	return ok
+}
+
+// マニフェスト (sha3sum形式) の1行
+type manifestEntry struct {
+	digest []byte
+	name   string
+}
+
+// sha3sum形式のマニフェストを読み込む (バックスラッシュでエスケープされた名前も復元する)
+func parseManifest(r io.Reader) ([]manifestEntry, error) {
+	var entries []manifestEntry
+	sc := bufio.NewScanner(r)
+	for lineNo := 1; sc.Scan(); lineNo++ {
+		line := strings.TrimSuffix(sc.Text(), "\r")
+		if line == "" {
+			continue
+		}
+
+		escaped := strings.HasPrefix(line, "\\")
+		if escaped {
+			line = line[1:]
+		}
+
+		// "<16進数>  <名前>" (バイナリモードの "<16進数> *<名前>" も受け付ける)
+		hexDigest, name, ok := strings.Cut(line, " ")
+		// -head の出力の "head:N:" ラベル (照合する側も同じ -head を指定する)
+		if rest, found := strings.CutPrefix(hexDigest, headLabelPrefix); found {
+			if _, d, found := strings.Cut(rest, ":"); found {
+				hexDigest = d
+			}
+		}
+		if ok && (strings.HasPrefix(name, " ") || strings.HasPrefix(name, "*")) {
+			name = name[1:]
+		}
+		digest, err := hex.DecodeString(hexDigest)
+		if !ok || err != nil || name == "" {
+			return nil, fmt.Errorf("マニフェストの %d 行目の形式が不正です", lineNo)
+		}
+		if escaped {
+			name = unescapeName(name)
+		}
+		entries = append(entries, manifestEntry{digest: digest, name: name})
+	}
+	return entries, sc.Err()
+}
+
+// writeSumLineでエスケープした名前を元に戻す
+func unescapeName(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+			if s[i] == 'n' {
+				b.WriteByte('\n')
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// マニフェストの各ファイルを照合して "名前: OK" / "名前: FAILED" を出力する
+// マニフェストにあってディスクにないファイル、マニフェストのエントリと同じディレクトリにあるのに
+// マニフェストに載っていないファイルは警告として標準エラーに出力する
+// ダイジェストが一致しないファイルがあればfalseを返す (警告だけなら成功扱い)
+// マニフェストや照合するファイルを読み込めなかった場合はエラーを返す
+func (fh *fileHasher) checkManifest(manifestPath string, w io.Writer) (bool, error) {
+	f, err := os.Open(manifestPath)
+	if err != nil {
+		return true, err
+	}
+	entries, err := parseManifest(f)
+	f.Close()
+	if err != nil {
+		return true, fmt.Errorf("%s: %w", manifestPath, err)
+	}
+
+	ok := true
+	var readErr error
+	listed := make(map[string]bool)
+	dirs := make(map[string]bool)
+	for _, e := range entries {
+		listed[filepath.Clean(e.name)] = true
+		dirs[filepath.Dir(e.name)] = true
+
+		digest, err := fh.hashFile(e.name)
+		switch {
+		case os.IsNotExist(err):
+			fmt.Fprintf(os.Stderr, "警告: ディスクに存在しないファイル: %s\n", e.name)
+		case err != nil:
+			writeCheckLine(w, e.name, fmt.Sprintf("FAILED (%v)", err))
+			readErr = errors.New("一部のファイルを読み込めませんでした")
+		case bytes.Equal(digest, e.digest):
+			writeCheckLine(w, e.name, "OK")
+		default:
+			writeCheckLine(w, e.name, "FAILED")
+			ok = false
+		}
+	}
+
+	// マニフェストに載っていないファイル (マニフェスト自身は除く)
+	self := filepath.Clean(manifestPath)
+	for dir := range dirs {
+		des, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, de := range des {
+			name := filepath.Join(dir, de.Name())
+			if de.Type().IsRegular() && !listed[name] && name != self {
+				fmt.Fprintf(os.Stderr, "警告: マニフェストにないファイル: %s\n", name)
+			}
+		}
+	}
+
+	return ok, readErr
+}
+
+// 中断再開用の状態を保存する間隔 (バイト単位)
+var resumeInterval int64 = 64 << 20
+
+// 読み込み済みのオフセットとスポンジの状態を状態ファイルに書き出す
+// 一時ファイルに書いてから置き換えるので、途中で強制終了しても壊れた状態は残らない
+func saveResumeState(statePath string, offset int64, sp *Sponge) error {
+	st, err := sp.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	b := binary.BigEndian.AppendUint64(nil, uint64(offset))
+	b = append(b, st...)
+
+	tmp := statePath + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, statePath)
+}
+
+// 状態ファイルがあればその続きから、なければ最初からファイルをスポンジspでハッシュする
+// 一定量ごとに状態ファイルを更新し、完了したら削除する
+func hashFileResumable(path, statePath string, sp *Sponge) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var offset int64
+
+	b, err := os.ReadFile(statePath)
+	switch {
+	case err == nil:
+		if len(b) < 8 {
+			return nil, fmt.Errorf("状態ファイルが不正です: %s", statePath)
+		}
+		offset = int64(binary.BigEndian.Uint64(b))
+		if err := sp.UnmarshalBinary(b[8:]); err != nil {
+			return nil, fmt.Errorf("%s: %w", statePath, err)
+		}
+
+		fi, err := f.Stat()
+		if err != nil {
+			return nil, err
+		}
+		if offset > fi.Size() {
+			return nil, fmt.Errorf("状態ファイルのオフセット %d がファイルサイズ %d を超えています", offset, fi.Size())
+		}
+		if _, err := f.Seek(offset, io.SeekStart); err != nil {
+			return nil, err
+		}
+	case !os.IsNotExist(err):
+		return nil, err
+	}
+
+	for {
+		n, err := io.CopyN(sp, f, resumeInterval)
+		offset += n
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err := saveResumeState(statePath, offset, sp); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := os.Remove(statePath); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	return sp.Sum(nil), nil
+}
+
+// 外部実装の検証用テストベクタを生成 (1行に "<入力の16進数> <ダイジェストの16進数>")
+// i行目 (1始まり) の入力はiバイトで、内容はseedから導いたSHAKE256の出力列なので、同じseedなら常に同じ結果になる
+func genVectors(w io.Writer, n int, seed int64, newHash func() hash.Hash) {
+	drbg := newShake256()
+	drbg.Write(binary.BigEndian.AppendUint64([]byte("genvectors"), uint64(seed)))
+	rng := drbg.Squeeze()
+
+	for i := 1; i <= n; i++ {
+		input := make([]byte, i)
+		rng.Read(input)
+
+		h := newHash()
+		h.Write(input)
+		fmt.Fprintf(w, "%x %x\n", input, h.Sum(nil))
+	}
+}
+
+// sep区切り (NULまたは改行) のファイル名一覧を読み、各ファイルのハッシュをsha3sum形式で出力
+// 開けないファイルは報告して次へ進み、失敗したファイルがあればfalseを返す
+func (fh *fileHasher) hashNameList(r io.Reader, w io.Writer, sep byte) bool {
+	br := bufio.NewReader(r)
+	ok := true
+
+	for {
+		name, err := br.ReadString(sep)
+		name = strings.TrimSuffix(name, string(sep))
+		if sep == '\n' {
+			name = strings.TrimSuffix(name, "\r")
+		}
+		if name != "" && !fh.writeSum(w, name) {
+			ok = false
+		}
+
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "入力エラー:", err)
+			return false
+		}
+	}
+
+	return ok
+}
+
+// -echo-bytes で表示する16進ダンプの最大バイト数
+const echoPreviewLen = 64
+
+// ハッシュしたバイト数と先頭echoPreviewLenバイトの16進ダンプを書き出す
+func echoInput(w io.Writer, b []byte) {
+	fmt.Fprintf(w, "ハッシュしたバイト数: %d\n", len(b))
+	preview := b
+	if len(preview) > echoPreviewLen {
+		preview = preview[:echoPreviewLen]
+	}
+	fmt.Fprint(w, hex.Dump(preview))
+	if len(b) > len(preview) {
+		fmt.Fprintf(w, "... (残り%dバイト省略)\n", len(b)-len(preview))
+	}
+}
+
+// アルゴリズムのパラメータと、outLenバイト出力時の安全性 (ビット単位) を出力する
+// 衝突耐性は min(出力長/2, キャパシティ/2)、原像耐性は min(出力長, キャパシティ/2)
+// (固定長のSHA3ではキャパシティが出力長の2倍なので、それぞれ出力長/2と出力長になる)
+func writeSecurity(w io.Writer, p Params, outLen int) {
+	d := outLen * 8
+	fmt.Fprintf(w, "アルゴリズム: %s\n", p.Name)
+	fmt.Fprintf(w, "レート: %d バイト (%d ビット)\n", p.Rate/8, p.Rate)
+	fmt.Fprintf(w, "キャパシティ: %d ビット\n", p.Capacity)
+	fmt.Fprintf(w, "出力長: %d バイト\n", outLen)
+	fmt.Fprintf(w, "衝突耐性: %d ビット\n", min(d/2, p.Capacity/2))
+	fmt.Fprintf(w, "原像耐性: %d ビット\n", min(d, p.Capacity/2))
+	if p.XOF {
+		fmt.Fprintln(w, "(SHAKEの安全性は出力長にも依存し、衝突耐性は min(出力長*8/2, キャパシティ/2) になる)")
+	}
+}
+
+// 計測対象のハッシュ関数
+type benchTarget struct {
+	name    string
+	newHash func() hash.Hash
+}
+
+// bufをd以上の時間繰り返しハッシュし、スループット (MB/s) を返す
+func benchThroughput(newHash func() hash.Hash, buf []byte, d time.Duration) float64 {
+	var total int64
+	start := time.Now()
+	for time.Since(start) < d {
+		h := newHash()
+		h.Write(buf)
+		h.Sum(nil)
+		total += int64(len(buf))
+	}
+	return float64(total) / time.Since(start).Seconds() / 1e6
+}
+
+// 各ハッシュ関数のスループットを表にして出力する
+func writeBench(w io.Writer, targets []benchTarget, size int, d time.Duration) {
+	buf := make([]byte, size)
+	for i := range buf {
+		buf[i] = byte(i)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "アルゴリズム\t入力サイズ\tスループット\n")
+	for _, t := range targets {
+		fmt.Fprintf(tw, "%s\t%d バイト\t%.1f MB/s\n", t.name, size, benchThroughput(t.newHash, buf, d))
+	}
+	tw.Flush()
+}
+
+// Keccakパディング (0x01) 使用時のmultihashコード
+var keccakMultihashCodes = map[string]uint64{
+	"sha3-224": 0x1a,
+	"sha3-256": 0x1b,
+	"sha3-384": 0x1c,
+	"sha3-512": 0x1d,
+}
+
+// CLIで選択されたアルゴリズム
+type cliAlgorithm struct {
+	name    string // 表示名
+	newHash func() hash.Hash
+	mhCode  uint64 // multihashのアルゴリズムコード
+	sponge  bool   // newHashが*Spongeを返すか (SHA-256以外)
+}
+
+// FIPS 180-4のSHA-256 (-actual-sha256、-algorithms の sha256)
+func sha256Algorithm() cliAlgorithm {
+	return cliAlgorithm{
+		name:    "SHA-256",
+		newHash: func() hash.Hash { return newSHA256() },
+		mhCode:  sha256MultihashCode,
+	}
+}
+
+// -algorithm、-padding、-actual-sha256 の指定からアルゴリズムを決める
+// paddingが "keccak" の場合はドメイン分離バイトを0x01にした旧Keccak (Keccak-256など) になる
+func selectAlgorithm(spec, padding string, actualSHA256 bool) (cliAlgorithm, error) {
+	if actualSHA256 {
+		if spec != "sha3-256" || padding != "sha3" {
+			return cliAlgorithm{}, errors.New("-actual-sha256 は -algorithm、-padding と同時に指定できません")
+		}
+		return sha256Algorithm(), nil
+	}
+
+	opts, err := ParseSpec(spec)
+	if err != nil {
+		return cliAlgorithm{}, err
+	}
+	v, _ := opts.variant()
+
+	name := strings.ToUpper(opts.Algorithm)
+	mhCode := v.mhCode
+	switch padding {
+	case "sha3":
+	case "keccak":
+		if v.xof {
+			return cliAlgorithm{}, errors.New("SHAKEは0x1fのパディングが必須のため -padding keccak は使用できません")
+		}
+		name = "Keccak-" + strings.TrimPrefix(opts.Algorithm, "sha3-")
+		mhCode = keccakMultihashCodes[opts.Algorithm]
+		opts.Domain = DomainKeccak
+	default:
+		return cliAlgorithm{}, fmt.Errorf("-padding には sha3 または keccak を指定してください: %q", padding)
+	}
+
+	return cliAlgorithm{
+		name: name,
+		newHash: func() hash.Hash {
+			sp, _ := New(opts)
+			return sp
+		},
+		mhCode: mhCode,
+		sponge: true,
+	}, nil
+}
+
+// 環境変数 SHA3_ALGORITHM と SHA3_OUTPUT_LEN から -algorithm の既定値を作る
+// どちらも未設定なら "sha3-256"。値は -algorithm と同じく ParseSpec で検証する
+func algorithmFromEnv() (string, error) {
+	spec := "sha3-256"
+	if v := os.Getenv("SHA3_ALGORITHM"); v != "" {
+		spec = v
+	}
+	if v := os.Getenv("SHA3_OUTPUT_LEN"); v != "" {
+		if strings.Contains(spec, ":") {
+			return "", fmt.Errorf("SHA3_ALGORITHM に出力長が含まれているため SHA3_OUTPUT_LEN は指定できません: %q", spec)
+		}
+		spec += ":" + v
+	}
+	if _, err := ParseSpec(spec); err != nil {
+		return "", fmt.Errorf("環境変数 SHA3_ALGORITHM/SHA3_OUTPUT_LEN が不正です: %w", err)
+	}
+	return spec, nil
+}
+
+// 終了コード
+const (
+	exitOK       = 0 // 成功
+	exitUsage    = 2 // フラグや引数の誤り (flagパッケージの解析エラーと同じ値)
+	exitMismatch = 3 // 照合や -cmp でダイジェストが一致しなかった
+	exitIO       = 4 // ファイルの読み書きやネットワークのエラー
+)
+
+// runが返すエラーの種類 (errors.Isで判定し、exitCodeで終了コードに対応付ける)
+var (
+	ErrBadArg       = errors.New("フラグや引数の誤り")
+	ErrVerifyFailed = errors.New("ダイジェストが一致しません")
+	ErrOpen         = errors.New("入出力エラー")
+)
+
+// 一部の入力をハッシュできなかったことを表すエラー (出力は書き終えてから返す)
+var errSomeInputsFailed = errors.New("一部のファイルをハッシュできませんでした")
+
+// 種類を持つCLIのエラー (表示するのはerrのメッセージのみ)
+type cliError struct {
+	kind error // ErrBadArg、ErrVerifyFailed、ErrOpen のいずれか
+	err  error
+}
+
+func (e *cliError) Error() string { return e.err.Error() }
+
+func (e *cliError) Unwrap() []error { return []error{e.kind, e.err} }
+
+// 使い方の誤りを表すエラー
+func usageError(msg string) error {
+	return &cliError{kind: ErrBadArg, err: errors.New(msg)}
+}
+
+// エラーの種類から終了コードを決める (種類のないエラーは入出力エラーとして扱う)
+func exitCode(err error) int {
+	switch {
+	case err == nil:
+		return exitOK
+	case errors.Is(err, ErrVerifyFailed):
+		return exitMismatch
+	case errors.Is(err, ErrBadArg):
+		return exitUsage
+	default:
+		return exitIO
+	}
+}
+
+func run() error {
+	defaultAlgorithm, envErr := algorithmFromEnv()
+
+	actualSHA256 := flag.Bool("actual-sha256", false, "SHA3-256ではなくFIPS 180-4のSHA-256を計算する")
+	nulTerminated := flag.Bool("0", false, "標準入力からNUL区切りのファイル名を読み、各ファイルをハッシュする")
+	resume := flag.String("resume", "", "状態ファイルを使い、中断したファイルのハッシュ計算を再開する")
+	dumpState := flag.Bool("dump-state", false, "吸収後 (絞り出し前) の内部状態の25レーンを表示する")
+	traceSteps := flag.Bool("trace", false, "対話モードでKeccak-f[1600]の各ラウンドのθ、ρπ、χ、ιの後の状態を表示する")
+	showPadded := flag.Bool("show-padded", false, "対話モードでパディング後のメッセージ全体を16進ダンプで表示する")
+	outFile := flag.String("o", "", "結果を標準出力ではなくファイルに書き込む (一時ファイル経由で置き換え)")
+	multihashEnc := flag.String("multihash", "", "ダイジェストをmultihash形式で出力する (hex または base58)")
+	genCount := flag.Int("genvectors", 0, "乱数入力 (長さ1からNバイト) のテストベクタをN行出力する")
+	seed := flag.Int64("seed", 1, "-genvectors の入力を決めるシード")
+	head := flag.Int64("head", 0, "各ファイルの先頭Nバイトのみをハッシュし、ダイジェストに head:実際のバイト数: を付けて表示する (0ならファイル全体)")
+	fromFile := flag.String("from-file", "", "ハッシュするファイル名の一覧 (1行1ファイル、-0 指定時はNUL区切り) をファイルから読む")
+	decimal := flag.Bool("decimal", false, "ダイジェストをビッグエンディアンの符号なし整数とみなし、10進数で表示する")
+	group := flag.Int("group", 0, "16進数のダイジェストをN文字ごとに空白で区切って表示する (0なら区切らない)")
+	url := flag.String("url", "", "HTTP(S)のURLから取得した内容をハッシュする")
+	timeout := flag.Duration("timeout", 0, "-url の取得全体のタイムアウト (0なら無制限)")
+	writeManifest := flag.String("write-manifest", "", "引数のファイルをハッシュしてマニフェスト (sha3sum形式) に書き出す")
+	check := flag.String("c", "", "マニフェストのファイルを照合し、追加・削除されたファイルも警告する")
+	algorithm := flag.String("algorithm", defaultAlgorithm, "アルゴリズム (sha3-224/256/384/512、shake128/256、SHAKEは :出力バイト数 を指定可、既定値は環境変数 SHA3_ALGORITHM と SHA3_OUTPUT_LEN でも設定できる)")
+	algorithmList := flag.String("algorithms", "", "カンマ区切りの複数のアルゴリズム (例: sha3-256,shake256:32,keccak-256) で各ファイルを一度の読み込みでハッシュする")
+	padding := flag.String("padding", "sha3", "パディング (sha3: FIPS 202の0x06、keccak: 旧Keccakの0x01)")
+	keepNewline := flag.Bool("keep-newline", false, "対話モードで前後の空白を取り除かず、行末の改行も含めてハッシュする")
+	history := flag.Bool("history", false, "対話モードの入力をキャッシュディレクトリの履歴ファイル (権限0600) に保存し、起動時に以前の入力を再計算して表示する")
+	echoBytes := flag.Bool("echo-bytes", false, "対話モードで実際にハッシュしたバイト数と先頭部分の16進ダンプを表示する")
+	chunked := flag.Bool("chunked", false, "ファイル (なければ標準入力) をチャンクごとにハッシュし、最後の行にルートを出力する")
+	chunkSize := flag.Int64("chunk-size", 1<<20, "-chunked のチャンクのバイト数")
+	bench := flag.Bool("bench", false, "選択したアルゴリズムで合成データを繰り返しハッシュし、スループットを表示する")
+	benchSize := flag.Int("bench-size", 1<<20, "-bench でハッシュする合成データのバイト数")
+	benchDuration := flag.Duration("bench-duration", time.Second, "-bench でアルゴリズムごとに計測する時間")
+	benchCompare := flag.Bool("bench-compare", false, "-bench で標準ライブラリのcrypto/sha256とcrypto/sha3も計測する")
+	gunzip := flag.Bool("gunzip", false, "gzip形式の入力を展開しながら、展開後の内容をハッシュする")
+	decompress := flag.Bool("decompress", false, "入力の先頭のマジックバイトで圧縮形式を判定し、gzipなら展開後の内容をハッシュする (zstdは未対応)")
+	follow := flag.Bool("follow", false, "追記され続けるファイルを読み続け、増えるたびにそれまでの全体のダイジェストを出力する")
+	followInterval := flag.Duration("follow-interval", time.Second, "-follow でファイルの増加を確認する間隔")
+	followIdle := flag.Duration("follow-idle", 0, "-follow でファイルが増えないまま経過したら終了する時間 (0なら終了しない)")
+	cachePath := flag.String("cache", "", "ファイルのダイジェストをキャッシュするファイル (パス・サイズ・更新時刻が同じファイルは再計算しない、-head 指定時は使わない)")
+	noCache := flag.Bool("no-cache", false, "-cache の指定を無視して、すべてのファイルをハッシュし直す")
+	parallelBlock := flag.Int("parallel-block", 0, "ファイルをこのブロック長 (バイト) のParallelHash256でハッシュする (0なら使わない)")
+	parallelism := flag.Int("parallelism", runtime.NumCPU(), "-parallel-block で並行してハッシュするゴルーチンの数")
+	autotune := flag.Bool("autotune", false, "64MiB以上のファイルをハッシュする前に、読み込みバッファの長さをいくつか試して最も速いものを使う")
+	tee := flag.Bool("tee", false, "標準入力をそのまま標準出力へコピーしながらハッシュし、ダイジェストを最後に標準エラーへ出力する")
+	selfHash := flag.Bool("self-hash", false, "実行中のこのプログラム自身のファイルをハッシュする (改ざんの確認用)")
+	cmp := flag.Bool("cmp", false, "2つのファイルの内容を比べ、identical (終了コード0) か differ (終了コード3) を表示する")
+	showProgress := flag.Bool("progress", false, "複数のファイルをハッシュする際、標準エラーが端末なら処理したファイル数と累計バイト数を表示する")
+	jsonOut := flag.Bool("json", false, "ファイルごとの結果をsha3sum形式ではなく1行1件のJSON (file、digest) で出力する")
+	timing := flag.Bool("timing", false, "-json の結果にハッシュの所要時間 (duration_ns) とスループット (throughput_mbps) を含める")
+	statsMode := flag.Bool("stats", false, "ハッシュした入力の件数と合計・最小・最大・平均サイズを最後に標準エラーへ出力する")
+	stringMode := flag.Bool("s", false, "引数をファイル名ではなく文字列としてハッシュする")
+	join := flag.Bool("join", false, "-s の引数を -sep で連結し、1つの文字列としてハッシュする")
+	sep := flag.String("sep", "", "-join で引数を連結する区切り文字列")
+	security := flag.Bool("security", false, "選択したアルゴリズムのレートと衝突・原像耐性 (ビット) を表示する")
+	warnUTF8 := flag.Bool("warn-utf8", false, "対話モードの入力が正しいUTF-8でない場合に標準エラーへ警告する (ハッシュする内容は変わらない)")
+	withMeta := flag.Bool("with-meta", false, "ファイル名とサイズも含めてTupleHash256でハッシュする (内容だけのダイジェストとは互換性がない)")
+	csvMode := flag.Bool("csv", false, "引数のファイル (なければ標準入力) をCSVとして解析し、行ごとにフィールド単位のTupleHash256を出力する")
+	flag.Usage = func() {
+		out := flag.CommandLine.Output()
+		fmt.Fprintf(out, "使い方: %s [フラグ] [ファイル...]\n", filepath.Base(os.Args[0]))
+		flag.PrintDefaults()
+		fmt.Fprintf(out, "\n終了コード: %d 成功、%d フラグや引数の誤り、%d 照合の不一致、%d 入出力エラー\n",
+			exitOK, exitUsage, exitMismatch, exitIO)
+	}
+	flag.Parse()
+
+	// 明示したフラグは環境変数より優先する
+	explicit := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+	switch {
+	case explicit["algorithm"]:
+	case envErr != nil && !*actualSHA256:
+		return &cliError{kind: ErrBadArg, err: envErr}
+	case *actualSHA256:
+		*algorithm = "sha3-256"
+	}
+
+	alg, err := selectAlgorithm(*algorithm, *padding, *actualSHA256)
+	if err != nil {
+		return &cliError{kind: ErrBadArg, err: err}
+	}
+	name, newHash, mhCode := alg.name, alg.newHash, alg.mhCode
+	sum := func(b []byte) []byte {
+		h := newHash()
+		h.Write(b)
+		return h.Sum(nil)
+	}
+	if alg.name == "SHA3-256" {
+		fmt.Fprintln(os.Stderr, "注意: このツールが計算するのはSHA3-256です (SHA-256は -actual-sha256 を指定)")
+	}
+
+	if *security {
+		if !alg.sponge {
+			return usageError("-security はKeccak系のアルゴリズムでのみ使用できます")
+		}
+		opts, _ := ParseSpec(*algorithm)
+		p, _ := paramsFor(opts.Algorithm)
+		outLen := p.Size
+		if opts.Length > 0 {
+			outLen = opts.Length
+		}
+		writeSecurity(os.Stdout, p, outLen)
+		return nil
+	}
+
+	if *bench {
+		if *benchSize <= 0 || *benchDuration <= 0 {
+			return usageError("-bench-size と -bench-duration には正の値を指定してください")
+		}
+		targets := []benchTarget{{name, newHash}}
+		if *benchCompare {
+			targets = append(targets,
+				benchTarget{"crypto/sha256", sha256.New},
+				benchTarget{"crypto/sha3 (SHA3-256)", func() hash.Hash { return sha3.New256() }},
+			)
+		}
+		writeBench(os.Stdout, targets, *benchSize, *benchDuration)
+		return nil
+	}
+
+	if (*dumpState || *showPadded || *traceSteps) && !alg.sponge {
+		return usageError("-dump-state、-show-padded、-trace はKeccak系のアルゴリズムでのみ使用できます")
+	}
+
+	// ダイジェストの表示形式
+	encode := hex.EncodeToString
+	switch *multihashEnc {
+	case "":
+	case "hex":
+		encode = func(d []byte) string { return hex.EncodeToString(multihash(mhCode, d)) }
+	case "base58":
+		encode = func(d []byte) string { return base58Encode(multihash(mhCode, d)) }
+	default:
+		return usageError("-multihash には hex または base58 を指定してください")
+	}
+
+	// 10進数表示 (ダイジェストの先頭バイトを最上位とするビッグエンディアンの整数)
+	if *decimal {
+		if *multihashEnc != "" || *group > 0 || *writeManifest != "" {
+			return usageError("-decimal は -multihash、-group、-write-manifest と同時に指定できません")
+		}
+		encode = decimalString
+	}
+
+	// 16進数表示の区切り (表示のみでダイジェストには影響しない)
+	switch {
+	case *group < 0:
+		return usageError("-group には0以上の値を指定してください")
+	case *group > 0 && *multihashEnc == "base58":
+		return usageError("-group は16進数表示でのみ使用できます")
+	case *group > 0:
+		hexEncode := encode
+		encode = func(d []byte) string { return groupString(hexEncode(d), *group) }
+	}
+
+	// 入力サイズの統計 (失敗して終了する場合も出力する)
+	var stats *inputStats
+	if *statsMode {
+		stats = new(inputStats)
+		defer stats.write(os.Stderr)
+	}
+
+	// 結果の出力先 (-o 指定時は書き込みが完了してから置き換える)
+	// 一部の入力の失敗では -o の書き込みを中断せず、置き換えを終えてから失敗を返す
+	emit := func(write func(io.Writer) error) error {
+		var err, inputErr error
+		writeAll := func(w io.Writer) error {
+			err := write(w)
+			if errors.Is(err, errSomeInputsFailed) {
+				inputErr, err = err, nil
+			}
+			return err
+		}
+		if *outFile == "" {
+			err = writeAll(os.Stdout)
+		} else {
+			err = writeFileAtomic(*outFile, writeAll)
+		}
+		if err == nil {
+			err = inputErr
+		}
+		if err != nil {
+			return &cliError{kind: ErrOpen, err: err}
+		}
+		return nil
+	}
+
+	// 展開後の内容をハッシュするか (展開後の長さは読み終えるまで分からない)
+	expand := *gunzip || *decompress
+
+	if *resume != "" {
+		if !alg.sponge || *head > 0 || *withMeta || expand || flag.NArg() != 1 {
+			return usageError("使い方: sha3 -resume 状態ファイル 入力ファイル (Keccak系のみ対応、-head、-with-meta、-gunzip、-decompress 不可)")
+		}
+		return emit(func(w io.Writer) error {
+			digest, err := hashFileResumable(flag.Arg(0), *resume, newHash().(*Sponge))
+			if err != nil {
+				return err
+			}
+			writeSumLine(w, encode(digest), flag.Arg(0))
+			return nil
+		})
+	}
+
+	if *head < 0 {
+		return usageError("-head には0以上の値を指定してください")
+	}
+	if *parallelBlock < 0 || *parallelism <= 0 || (*parallelBlock > 0 && *withMeta) {
+		return usageError("-parallel-block には0以上、-parallelism には正の値を指定してください (-with-meta 不可)")
+	}
+	if *withMeta && (*url != "" || *csvMode || *stringMode || expand) {
+		return usageError("-with-meta はファイルのハッシュでのみ使用できます (-url、-csv、-s、-gunzip、-decompress 不可)")
+	}
+	fh := &fileHasher{newHash: newHash, encode: encode, head: *head, withMeta: *withMeta, stats: stats, gunzip: *gunzip, decompress: *decompress,
+		parallelBlock: *parallelBlock, parallelism: *parallelism, json: *jsonOut, timing: *timing, autotune: *autotune}
+
+	if *timing && !*jsonOut {
+		return usageError("-timing は -json と組み合わせて使用します")
+	}
+	if *jsonOut && (*resume != "" || *follow || *selfHash || *cmp || *check != "" || *writeManifest != "" ||
+		*url != "" || *genCount > 0 || *stringMode || *chunked || *csvMode) {
+		return usageError("-json はファイルのハッシュ (引数、-0、-from-file) でのみ使用できます")
+	}
+
+	// -head の結果はハッシュしたバイト数のラベルを伴うのでキャッシュしない
+	// (読み込んだだけで保存すると、計算条件の違う既存のキャッシュを空で上書きしてしまう)
+	if *cachePath != "" && !*noCache && *head == 0 {
+		config := fmt.Sprintf("algorithm=%s padding=%s actual-sha256=%t head=%d with-meta=%t gunzip=%t decompress=%t parallel-block=%d",
+			*algorithm, *padding, *actualSHA256, *head, *withMeta, *gunzip, *decompress, *parallelBlock)
+		cache, err := loadDigestCache(*cachePath, config)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "警告: キャッシュを使わずに続行します:", err)
+		}
+		fh.cache = cache
+		defer func() {
+			if err := cache.save(*cachePath); err != nil {
+				fmt.Fprintln(os.Stderr, "警告: キャッシュを保存できませんでした:", err)
+			}
+		}()
+	}
+
+	if *algorithmList != "" {
+		if flag.NArg() == 0 || *withMeta || *parallelBlock > 0 || *jsonOut || *multihashEnc != "" || *cachePath != "" || *outFile != "" {
+			return usageError("使い方: sha3 -algorithms a,b,c ファイル... (-with-meta、-parallel-block、-json、-multihash、-cache、-o 不可)")
+		}
+		algs, err := parseAlgorithmList(*algorithmList)
+		if err != nil {
+			return &cliError{kind: ErrBadArg, err: err}
+		}
+		ok := true
+		for _, name := range flag.Args() {
+			if !fh.writeMultiSum(os.Stdout, name, algs) {
+				ok = false
+			}
+		}
+		if !ok {
+			return &cliError{kind: ErrOpen, err: errSomeInputsFailed}
+		}
+		return nil
+	}
+
+	if *follow {
+		if flag.NArg() != 1 || *outFile != "" || *head > 0 || *withMeta || expand || *followInterval <= 0 || *followIdle < 0 {
+			return usageError("使い方: sha3 -follow [-follow-interval 間隔] [-follow-idle 時間] ファイル (-o、-head、-with-meta、-gunzip、-decompress 不可)")
+		}
+		f, err := os.Open(flag.Arg(0))
+		if err != nil {
+			return &cliError{kind: ErrOpen, err: err}
+		}
+		defer f.Close()
+		if err := fh.follow(f, os.Stdout, flag.Arg(0), *followInterval, *followIdle); err != nil {
+			return &cliError{kind: ErrOpen, err: err}
+		}
+		return nil
+	}
+
+	if *tee {
+		if flag.NArg() != 0 || *outFile != "" || *head > 0 || *withMeta || expand || *jsonOut {
+			return usageError("使い方: sha3 -tee < 入力 > 出力 (-o、-head、-with-meta、-gunzip、-decompress、-json 不可)")
+		}
+		n, digest, err := hashAndCopy(os.Stdout, os.Stdin, newHash())
+		if err != nil {
+			return &cliError{kind: ErrOpen, err: err}
+		}
+		fh.record(n)
+		writeSumLine(os.Stderr, fh.encode(digest), "-")
+		return nil
+	}
+
+	if *selfHash {
+		if flag.NArg() != 0 || *head > 0 || *withMeta || expand {
+			return usageError("使い方: sha3 -self-hash (-head、-with-meta、-gunzip、-decompress 不可)")
+		}
+		exe, err := os.Executable()
+		if err != nil {
+			return &cliError{kind: ErrOpen, err: err}
+		}
+		return emit(func(w io.Writer) error {
+			digest, err := fh.hashFile(exe)
+			if err != nil {
+				return err
+			}
+			writeSumLine(w, fh.encode(digest), exe)
+			return nil
+		})
+	}
+
+	if *cmp {
+		if flag.NArg() != 2 || *withMeta {
+			return usageError("使い方: sha3 -cmp ファイル1 ファイル2 (-with-meta 不可)")
+		}
+		same, err := fh.sameContent(flag.Arg(0), flag.Arg(1))
+		if err != nil {
+			return &cliError{kind: ErrOpen, err: err}
+		}
+		if !same {
+			fmt.Println("differ")
+			return ErrVerifyFailed
+		}
+		fmt.Println("identical")
+		return nil
+	}
+
+	if *check != "" {
+		ok, err := fh.checkManifest(*check, os.Stdout)
+		switch {
+		case !ok && err != nil:
+			// 不一致を優先し、読み込めなかったことは表示だけする
+			fmt.Fprintln(os.Stderr, "エラー:", err)
+			return ErrVerifyFailed
+		case !ok:
+			return ErrVerifyFailed
+		case err != nil:
+			return &cliError{kind: ErrOpen, err: err}
+		}
+		return nil
+	}
+
+	if *writeManifest != "" {
+		if flag.NArg() == 0 || *outFile != "" {
+			return usageError("使い方: sha3 -write-manifest マニフェスト ファイル...")
+		}
+		*outFile = *writeManifest
+	}
+
+	if *url != "" {
+		client := &http.Client{Timeout: *timeout}
+		return emit(func(w io.Writer) error {
+			digest, err := fh.hashURL(client, *url)
+			if err != nil {
+				return err
+			}
+			writeSumLine(w, fh.encode(digest), *url)
+			return nil
+		})
+	}
+
+	if *genCount > 0 {
+		return emit(func(w io.Writer) error {
+			genVectors(w, *genCount, *seed, newHash)
+			return nil
+		})
+	}
+
+	if *join && !*stringMode {
+		return usageError("-join は -s と組み合わせて使用します")
+	}
+	if *stringMode {
+		if flag.NArg() == 0 {
+			return usageError("使い方: sha3 -s [-join [-sep 区切り]] 文字列...")
+		}
+		return emit(func(w io.Writer) error {
+			fh.hashStrings(w, flag.Args(), *join, *sep)
+			return nil
+		})
+	}
+
+	if *chunked {
+		if *chunkSize <= 0 || flag.NArg() > 1 || *head > 0 || *withMeta || expand {
+			return usageError("使い方: sha3 -chunked [-chunk-size バイト数] [ファイル] (-head、-with-meta、-gunzip、-decompress 不可)")
+		}
+		return emit(func(w io.Writer) error {
+			if flag.NArg() == 0 {
+				return fh.hashChunked(os.Stdin, w, *chunkSize)
+			}
+			f, err := os.Open(flag.Arg(0))
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			return fh.hashChunked(f, w, *chunkSize)
+		})
+	}
+
+	if *csvMode {
+		return emit(func(w io.Writer) error {
+			if flag.NArg() == 0 {
+				return fh.hashCSV(os.Stdin, w, "-")
+			}
+			for _, name := range flag.Args() {
+				f, err := os.Open(name)
+				if err != nil {
+					return err
+				}
+				err = fh.hashCSV(f, w, name)
+				f.Close()
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	}
+
+	if *fromFile != "" {
+		list, err := os.Open(*fromFile)
+		if err != nil {
+			return &cliError{kind: ErrOpen, err: err}
+		}
+		defer list.Close()
+
+		sep := byte('\n')
+		if *nulTerminated {
+			sep = 0
+		}
+		return emit(func(w io.Writer) error {
+			if !fh.hashNameList(list, w, sep) {
+				return errSomeInputsFailed
+			}
+			return nil
+		})
+	}
+
+	if *nulTerminated {
+		return emit(func(w io.Writer) error {
+			if !fh.hashNameList(os.Stdin, w, 0) {
+				return errSomeInputsFailed
+			}
+			return nil
+		})
+	}
+
+	// 引数で渡されたファイルをハッシュ
+	if flag.NArg() > 0 {
+		if *showProgress {
+			fh.progress = newProgress(flag.NArg())
+		}
+		return emit(func(w io.Writer) error {
+			if !fh.hashFiles(w, flag.Args()) {
+				return errSomeInputsFailed
+			}
+			return nil
+		})
+	}
+
+	if *jsonOut {
+		return usageError("-json はファイルのハッシュ (引数、-0、-from-file) でのみ使用できます")
+	}
+	if *outFile != "" {
+		return usageError("-o はファイル引数、-s、-self-hash、-0、-from-file、-chunked、-csv、-url、-resume、-genvectors のいずれかと組み合わせて使用します")
+	}
+
+	// 以前のセッションの入力を現在のアルゴリズムで再計算して表示
+	var historyFile string
+	if *history {
+		path, err := historyPath()
+		var inputs []string
+		if err == nil {
+			inputs, err = loadHistory(path)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "警告: 履歴を使わずに続行します:", err)
+		} else {
+			historyFile = path
+		}
+		if len(inputs) > 0 {
+			fmt.Printf("履歴 (%d 件):\n", len(inputs))
+			for _, in := range inputs {
+				fmt.Print(formatResult(name, in, sum([]byte(in)), formatOpts{encode: encode, sumLine: true}))
+			}
+		}
+	}
+
+	reader := bufio.NewReader(os.Stdin)
+
+	for {
+		fmt.Printf("\n%sハッシュ値を計算する文字列を入力してください (終了する場合は'q'を入力):\n> ", name)
+
+		input, err := reader.ReadString('\n')
+		if err != nil {
+			fmt.Println("入力エラー:", err)
+			continue
+		}
+
+		if !*keepNewline {
+			input = strings.TrimSpace(input)
+		}
+
+		if strings.TrimSpace(input) == "q" {
+			fmt.Println("プログラムを終了します")
+			return nil
+		}
+
+		// 文字化けした入力への注意 (ハッシュはそのままのバイト列で計算する)
+		if *warnUTF8 && !utf8.ValidString(input) {
+			fmt.Fprintln(os.Stderr, "警告: 入力が正しいUTF-8ではありません (端末の文字コードを確認してください)")
+		}
+
+		// ハッシュ値を計算
+		hash := sum([]byte(input))
+		fh.record(int64(len(input)))
+
+		if historyFile != "" {
+			if err := appendHistory(historyFile, input); err != nil {
+				fmt.Fprintln(os.Stderr, "警告: 履歴を保存できませんでした:", err)
+			}
+		}
+
+		// 16進数に変換して表示
+		fmt.Print(formatResult(name, input, hash, formatOpts{encode: encode}))
+
+		// ハッシュしたバイト列そのものの確認用
+		if *echoBytes {
+			echoInput(os.Stdout, []byte(input))
+		}
+
+		// パディングの確認用 (ドメイン分離バイトと末尾の0x80の位置)
+		if *showPadded {
+			dumpPadded(os.Stdout, newHash().(*Sponge), []byte(input))
+		}
+
+		// 置換の途中経過の表示 (吸収と絞り出しで実行するすべての置換)
+		if *traceSteps {
+			sp := newHash().(*Sponge)
+			perm := 0
+			sp.SetPermutation(func(lanes *[25]uint64) {
+				perm++
+				KeccakF1600Trace(lanes, func(round int, step string, l [25]uint64) {
+					fmt.Printf("置換 %d ラウンド %2d %s:\n", perm, round, step)
+					for y := 0; y < 5; y++ {
+						fmt.Printf("  %016x %016x %016x %016x %016x\n", l[5*y], l[5*y+1], l[5*y+2], l[5*y+3], l[5*y+4])
+					}
+				})
+			})
+			sp.Write([]byte(input))
+			sp.Sum(nil)
+		}
+
+		// 内部状態の表示 (ダイジェストには影響しない)
+		if *dumpState {
+			sp := newHash().(*Sponge)
+			sp.Write([]byte(input))
+			st := sp.absorbedState()
+			fmt.Println("吸収後の内部状態:")
+			dumpLanes(os.Stdout, &st)
+		}
+	}
+}
+
+// 対話モードの履歴ファイルのパス (ユーザーのキャッシュディレクトリ/sha3/history)
+func historyPath() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "sha3", "history"), nil
+}
+
+// 履歴ファイルから以前の入力を読み込む (ファイルがなければ空)
+func loadHistory(path string) ([]string, error) {
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var inputs []string
+	for _, line := range strings.Split(strings.TrimSuffix(string(b), "\n"), "\n") {
+		if strings.HasPrefix(line, "\\") {
+			line = unescapeName(line[1:])
+		}
+		inputs = append(inputs, line)
+	}
+	return inputs, nil
+}
+
+// 入力を履歴ファイルに追記する (改行を含む入力はwriteSumLineと同じ方法でエスケープする)
+// 入力には秘密情報が含まれうるため、ディレクトリは0700、ファイルは0600にする
+func appendHistory(path, input string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	// 以前から緩い権限で存在していた場合も絞る
+	if err := f.Chmod(0o600); err != nil {
+		f.Close()
+		return err
+	}
+
+	prefix := ""
+	input, escaped := escapeName(input)
+	if escaped {
+		prefix = "\\"
+	}
+	if _, err := fmt.Fprintf(f, "%s%s\n", prefix, input); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func main() {
+	err := run()
+	switch {
+	case err == nil:
+	case errors.Is(err, ErrBadArg):
+		fmt.Fprintln(os.Stderr, err)
+	case err != ErrVerifyFailed: // 不一致は結果の出力で伝えている
+		fmt.Fprintln(os.Stderr, "エラー:", err)
 	}
+	os.Exit(exitCode(err))
 }
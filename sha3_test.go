@@ -0,0 +1,3191 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/hmac"
+	"crypto/sha3"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"hash"
+	"io"
+	"math/big"
+	"math/bits"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"testing/iotest"
+	"time"
+)
+
+// 16進数の文字列をバイト列にする (テストベクタ用)
+func mustHex(t testing.TB, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestDoubleSum256(t *testing.T) {
+	// SHA3-256("") = a7ffc6f8...8434a をもう一度ハッシュした値
+	want := mustHex(t, "a1292c11ccdb876535c6699e8217e1a1294190d83e4233ecc490d32df17a4116")
+	if got := DoubleSum256(nil); !bytes.Equal(got, want) {
+		t.Errorf("DoubleSum256(\"\") = %x, want %x", got, want)
+	}
+}
+
+// 余り容量のあるスライスを渡しても、呼び出し側のバッファを書き換えない
+func TestSum256DoesNotWriteSpareCapacity(t *testing.T) {
+	big := make([]byte, 300)
+	for i := range big {
+		big[i] = 0xaa
+	}
+	want := bytes.Clone(big)
+
+	DoubleSum256(big[:10])
+	sha3_256(big[:137])
+	if !bytes.Equal(big, want) {
+		t.Errorf("呼び出し側のバッファが書き換えられました: %x", big[:20])
+	}
+}
+
+func TestSum256ReaderN(t *testing.T) {
+	for _, size := range []int{0, 1, 135, 136, 137, 1 << 16} {
+		data := bytes.Repeat([]byte{'x'}, size)
+		for name, sum := range map[string]func(io.Reader) ([]byte, int64, error){
+			"Sum256ReaderN": Sum256ReaderN,
+			"sum256Counted": sum256Counted,
+		} {
+			digest, n, err := sum(bytes.NewReader(data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n != int64(size) {
+				t.Errorf("%s %d バイト: n = %d", name, size, n)
+			}
+			if want := sha3_256(data); !bytes.Equal(digest, want) {
+				t.Errorf("%s %d バイト: ダイジェスト %x, want %x", name, size, digest, want)
+			}
+		}
+	}
+}
+
+// 途中で読み込みエラーになっても、nはそれまでに読み込んだバイト数を表す
+func TestSum256ReaderNPartialError(t *testing.T) {
+	errBroken := errors.New("接続が切れました")
+	r := io.MultiReader(bytes.NewReader(make([]byte, 1000)), iotest.ErrReader(errBroken))
+	digest, n, err := Sum256ReaderN(r)
+	if !errors.Is(err, errBroken) || digest != nil {
+		t.Fatalf("エラー %v、ダイジェスト %x", err, digest)
+	}
+	if n != 1000 {
+		t.Errorf("n = %d, want 1000", n)
+	}
+}
+
+// 3つのチャンクの各境界にチェックポイントを置き、1つのチャンクを壊すとその番号が報告される
+func TestVerifyCheckpoints(t *testing.T) {
+	const chunk = 1000
+	data := make([]byte, 3*chunk)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	var cps []Checkpoint
+	for off := chunk; off <= len(data); off += chunk {
+		cps = append(cps, Checkpoint{Offset: int64(off), Digest: sha3_256(data[:off])})
+	}
+
+	if err := verifyCheckpoints(bytes.NewReader(data), cps); err != nil {
+		t.Fatalf("壊していない入力: %v", err)
+	}
+
+	for bad := 0; bad < len(cps); bad++ {
+		corrupt := bytes.Clone(data)
+		corrupt[bad*chunk+chunk/2] ^= 1
+		err := verifyCheckpoints(bytes.NewReader(corrupt), cps)
+		var mismatch *CheckpointMismatchError
+		if !errors.As(err, &mismatch) {
+			t.Fatalf("チャンク %d を壊した入力: エラー %v", bad, err)
+		}
+		if mismatch.Index != bad || mismatch.Offset != cps[bad].Offset {
+			t.Errorf("チャンク %d を壊した入力: チェックポイント %d (オフセット %d) が報告されました", bad, mismatch.Index, mismatch.Offset)
+		}
+	}
+
+	// 入力がチェックポイントより短い
+	if err := verifyCheckpoints(bytes.NewReader(data[:chunk+1]), cps); err == nil {
+		t.Error("短い入力でエラーになりません")
+	}
+}
+
+// 絞り出しの途中でシリアライズ・復元して続けても、中断しない場合と同じ出力になる
+func TestSqueezerMarshalResume(t *testing.T) {
+	sp := newShake256()
+	sp.Write([]byte("deterministic stream"))
+	want := make([]byte, 800)
+	sp.Squeeze().Read(want)
+
+	// ブロックの途中 (300) とブロック境界ちょうど (272 = 2*136) で中断する
+	for _, split := range []int{0, 1, 136, 272, 300} {
+		sq := sp.Squeeze()
+		got := make([]byte, len(want))
+		sq.Read(got[:split])
+
+		b, err := sq.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var resumed Squeezer
+		if err := resumed.UnmarshalBinary(b); err != nil {
+			t.Fatal(err)
+		}
+		resumed.Read(got[split:])
+
+		if !bytes.Equal(got, want) {
+			t.Errorf("%d バイト目で中断: 続きの出力が一致しません", split)
+		}
+	}
+
+	if err := new(Squeezer).UnmarshalBinary([]byte("sq3\x01")); err == nil {
+		t.Error("短いデータを受け付けました")
+	}
+}
+
+// 比較用の平坦な配列 [25]uint64 (添字 x + 5y) のKeccak-f[1600] (ベンチマーク専用で本体には使わない)
+func keccakF1600Flat(a *[25]uint64) {
+	var c, d [5]uint64
+	var b [25]uint64
+	for round := 0; round < 24; round++ {
+		// θ
+		for x := 0; x < 5; x++ {
+			c[x] = a[x] ^ a[x+5] ^ a[x+10] ^ a[x+15] ^ a[x+20]
+		}
+		for x := 0; x < 5; x++ {
+			d[x] = c[(x+4)%5] ^ rotl64(c[(x+1)%5], 1)
+		}
+		for i := range a {
+			a[i] ^= d[i%5]
+		}
+		// ρとπ: (x, y) -> (y, 2x+3y)
+		for x := 0; x < 5; x++ {
+			for y := 0; y < 5; y++ {
+				b[y+5*((2*x+3*y)%5)] = rotl64(a[x+5*y], r[x][y])
+			}
+		}
+		// χ
+		for y := 0; y < 25; y += 5 {
+			for x := 0; x < 5; x++ {
+				a[y+x] = b[y+x] ^ (^b[y+(x+1)%5] & b[y+(x+2)%5])
+			}
+		}
+		// ι
+		a[0] ^= RC[round]
+	}
+}
+
+// ベンチマークで比べる2つの実装が同じ置換であること
+func TestFlatPermutationMatches(t *testing.T) {
+	var lanes [25]uint64
+	for i := range lanes {
+		lanes[i] = uint64(i) * 0x9e3779b97f4a7c15
+	}
+	want := lanes
+	KeccakF1600(&want)
+	keccakF1600Flat(&lanes)
+	if lanes != want {
+		t.Errorf("平坦な配列の置換の結果が一致しません")
+	}
+}
+
+// 入れ子の配列 [5][5]uint64 の状態 (現在の実装) の置換のみ
+func BenchmarkPermutationNested(b *testing.B) {
+	var s state
+	for i := 0; i < b.N; i++ {
+		s.keccakF1600()
+	}
+}
+
+// 平坦な配列 [25]uint64 の状態の置換のみ
+func BenchmarkPermutationFlat(b *testing.B) {
+	var a [25]uint64
+	for i := 0; i < b.N; i++ {
+		keccakF1600Flat(&a)
+	}
+}
+
+// startから1ずつ増えるnバイト (SP 800-185 のサンプルの鍵と入力)
+func seqBytes(start byte, n int) []byte {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = start + byte(i)
+	}
+	return b
+}
+
+// NIST SP 800-185 のKMACXOF256のサンプル (#4〜#6)
+func TestKMACXOF256Samples(t *testing.T) {
+	key := seqBytes(0x40, 32)
+	tests := []struct {
+		data          []byte
+		customization string
+		want          string
+	}{
+		{seqBytes(0, 4), "My Tagged Application",
+			"1755133f1534752aad0748f2c706fb5c784512cab835cd15676b16c0c6647fa96faa7af634a0bf8ff6df39374fa00fad9a39e322a7c92065a64eb1fb0801eb2b"},
+		{seqBytes(0, 200), "",
+			"ff7b171f1e8a2b24683eed37830ee797538ba8dc563f6da1e667391a75edc02ca633079f81ce12a25f45615ec89972031d18337331d24ceb8f8ca8e6a19fd98b"},
+		{seqBytes(0, 200), "My Tagged Application",
+			"d5be731c954ed7732846bb59dbe3a8e30f83e77a4bff4459f2f1c2b4ecebb8ce67ba01c62e8ab8578d2d499bd1bb276768781190020a306a97de281dcc30305d"},
+	}
+	for i, tt := range tests {
+		got := make([]byte, 64)
+		KMACXOF256(key, tt.data, []byte(tt.customization), got)
+		if want := mustHex(t, tt.want); !bytes.Equal(got, want) {
+			t.Errorf("サンプル #%d: %x, want %x", i+4, got, want)
+		}
+	}
+}
+
+// NIST SP 800-185 のKMAC256のサンプル (#4〜#6) をKMAC256とNewKMAC256の両方で確認する
+func TestKMAC256Samples(t *testing.T) {
+	key := seqBytes(0x40, 32)
+	tests := []struct {
+		data          []byte
+		customization string
+		want          string
+	}{
+		{seqBytes(0, 4), "My Tagged Application",
+			"20c570c31346f703c9ac36c61c03cb64c3970d0cfc787e9b79599d273a68d2f7f69d4cc3de9d104a351689f27cf6f5951f0103f33f4f24871024d9c27773a8dd"},
+		{seqBytes(0, 200), "",
+			"75358cf39e41494e949707927cee0af20a3ff553904c86b08f21cc414bcfd691589d27cf5e15369cbbff8b9a4c2eb17800855d0235ff635da82533ec6b759b69"},
+		{seqBytes(0, 200), "My Tagged Application",
+			"b58618f71f92e1d56c1b8c55ddd7cd188b97b4ca4d99831eb2699a837da2e4d970fbacfde50033aea585f1a2708510c32d07880801bd182898fe476876fc8965"},
+	}
+	for i, tt := range tests {
+		want := mustHex(t, tt.want)
+		if got := KMAC256(key, tt.data, []byte(tt.customization), 64); !bytes.Equal(got, want) {
+			t.Errorf("サンプル #%d: KMAC256 = %x, want %x", i+4, got, want)
+		}
+
+		// 1バイトずつ書き込んでも、途中でSumを呼んでも同じMACになる
+		h := NewKMAC256(key, []byte(tt.customization), 64)
+		for j := range tt.data {
+			h.Write(tt.data[j : j+1])
+			if j == len(tt.data)/2 {
+				h.Sum(nil)
+			}
+		}
+		if got := h.Sum(nil); !bytes.Equal(got, want) {
+			t.Errorf("サンプル #%d: NewKMAC256 = %x, want %x", i+4, got, want)
+		}
+
+		// Resetすると鍵を吸収した直後に戻る
+		h.Reset()
+		h.Write(tt.data)
+		if got := h.Sum(nil); !bytes.Equal(got, want) {
+			t.Errorf("サンプル #%d: Reset後 = %x, want %x", i+4, got, want)
+		}
+	}
+}
+
+func TestStretchSum256(t *testing.T) {
+	data, salt := []byte("password"), []byte("salt")
+
+	// 1回目は H(salt || data)、2回目は H(salt || 1回目)
+	one := StretchSum256(data, salt, 1)
+	if want := sha3_256(append(bytes.Clone(salt), data...)); !bytes.Equal(one, want) {
+		t.Errorf("1回: %x, want %x", one, want)
+	}
+	two := StretchSum256(data, salt, 2)
+	if want := sha3_256(append(bytes.Clone(salt), one...)); !bytes.Equal(two, want) {
+		t.Errorf("2回: %x, want %x", two, want)
+	}
+
+	// 同じ引数なら同じ値、回数やソルトが違えば別の値
+	if a, b := StretchSum256(data, salt, 1000), StretchSum256(data, salt, 1000); !bytes.Equal(a, b) {
+		t.Error("同じ引数で結果が変わりました")
+	}
+	if bytes.Equal(StretchSum256(data, salt, 1000), StretchSum256(data, salt, 1001)) {
+		t.Error("回数を変えても結果が同じです")
+	}
+	if bytes.Equal(StretchSum256(data, salt, 10), StretchSum256(data, []byte("pepper"), 10)) {
+		t.Error("ソルトを変えても結果が同じです")
+	}
+}
+
+func TestStretchSum256RejectsZeroIterations(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("iterations = 0 でpanicしません")
+		}
+	}()
+	StretchSum256([]byte("x"), nil, 0)
+}
+
+// -actual-sha256 で選ばれる自前の圧縮関数によるSHA-256がFIPS 180-4のベクタと一致する
+// ("", "abc", 448ビットの2ブロックのメッセージ、"a"の100万回の繰り返し)
+func TestActualSHA256(t *testing.T) {
+	alg, err := selectAlgorithm("sha3-256", "sha3", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := alg.newHash().(*sha256Digest); !ok {
+		t.Fatalf("-actual-sha256 のハッシュが %T です", alg.newHash())
+	}
+	for _, tc := range []struct {
+		in   string
+		want string
+	}{
+		{"", "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"},
+		{"abc", "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"},
+		{"abcdbcdecdefdefgefghfghighijhijkijkljklmklmnlmnomnopnopq", "248d6a61d20638b8e5c026930c3e6039a33ce45964ff2167f6ecedd419db06c1"},
+		{strings.Repeat("a", 1000000), "cdc76e5c9914fb9281a1c7e284d73e67f1809a48a497200e046d39ccc7112cd0"},
+	} {
+		if got := hex.EncodeToString(sha256Sum([]byte(tc.in))); got != tc.want {
+			t.Errorf("sha256Sum(%d バイト) = %s, want %s", len(tc.in), got, tc.want)
+		}
+
+		// 64バイトの境界をまたぐ不揃いな書き込み
+		h := alg.newHash()
+		for p, n := []byte(tc.in), 1; len(p) > 0; n = n%97 + 13 {
+			n = min(n, len(p))
+			h.Write(p[:n])
+			p = p[n:]
+		}
+		if got := hex.EncodeToString(h.Sum(nil)); got != tc.want {
+			t.Errorf("%s ストリーミング (%d バイト) = %s, want %s", alg.name, len(tc.in), got, tc.want)
+		}
+	}
+}
+
+// 既定のSHA3-256では標準エラーに注意が表示され、-actual-sha256 では表示されない
+func TestSHA256ConfusionNote(t *testing.T) {
+	const note = "このツールが計算するのはSHA3-256です"
+	if _, stderr, _ := runCLI(t, "", "-s", "abc"); !strings.Contains(stderr, note) {
+		t.Errorf("既定のSHA3-256で注意が表示されません: %q", stderr)
+	}
+	stdout, stderr, _ := runCLI(t, "", "-actual-sha256", "-s", "abc")
+	if strings.Contains(stderr, note) {
+		t.Errorf("-actual-sha256 で注意が表示されました: %q", stderr)
+	}
+	if want := "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad  abc\n"; stdout != want {
+		t.Errorf("-actual-sha256 -s abc = %q, want %q", stdout, want)
+	}
+}
+
+// 複数のゴルーチンで共有できるSHA3-256
+func ExampleConcurrentHasher_Sum256() {
+	var h ConcurrentHasher
+	fmt.Printf("%x\n", h.Sum256([]byte("abc")))
+	// Output: 3a985da74fe225b2045c172d6bd390bd855f086e3e9d525b46bfe24511431532
+}
+
+// アルゴリズムを指定してスポンジを作り、ストリーミングでハッシュする
+func ExampleNew() {
+	sp, err := New(Options{Algorithm: "sha3-256"})
+	if err != nil {
+		panic(err)
+	}
+	sp.Write([]byte("hello, "))
+	sp.Write([]byte("world"))
+	fmt.Printf("%x\n", sp.Sum(nil))
+	// Output: bfb3959527d7a3f2f09def2f6915452d55a8f122df9e164d6f31c7fcf6093e14
+}
+
+// SHAKE256から任意の長さを絞り出す
+func ExampleSponge_Squeeze() {
+	sp, err := New(Options{Algorithm: "shake256"})
+	if err != nil {
+		panic(err)
+	}
+	sp.Write([]byte("abc"))
+
+	out := make([]byte, 16)
+	sp.Squeeze().Read(out)
+	fmt.Printf("%x\n", out)
+	// Output: 483366601360a8771c6863080cc4114d
+}
+
+// -resume: 途中まで進んだ状態ファイルから再開しても、中断しない場合と同じダイジェストになる
+func TestHashFileResumable(t *testing.T) {
+	defer func(old int64) { resumeInterval = old }(resumeInterval)
+	resumeInterval = 1000
+
+	dir := t.TempDir()
+	path, statePath := filepath.Join(dir, "input"), filepath.Join(dir, "state")
+	data := seqBytes(0, 5555)
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	want := sha3_256(data)
+
+	// 状態ファイルがなければ最初からハッシュし、完了後に状態ファイルを消す
+	got, err := hashFileResumable(path, statePath, newSponge256())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("中断なし: %x, want %x", got, want)
+	}
+	if _, err := os.Stat(statePath); !os.IsNotExist(err) {
+		t.Errorf("完了後も状態ファイルが残っています: %v", err)
+	}
+
+	// 2000バイトを吸収して状態を保存した直後に強制終了した場合
+	sp := newSponge256()
+	sp.Write(data[:2000])
+	if err := saveResumeState(statePath, 2000, sp); err != nil {
+		t.Fatal(err)
+	}
+	got, err = hashFileResumable(path, statePath, newSponge256())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("再開: %x, want %x", got, want)
+	}
+
+	// ファイルより先のオフセットを記録した状態ファイルはエラーにする
+	if err := saveResumeState(statePath, int64(len(data))+1, sp); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := hashFileResumable(path, statePath, newSponge256()); err == nil {
+		t.Error("ファイルサイズを超えるオフセットを受け付けました")
+	}
+}
+
+// 全ゼロの状態に対するKeccak-f[1600]の途中経過 (Keccakチームが公開している中間値と比べる)
+func TestKeccakF1600Trace(t *testing.T) {
+	var lanes [25]uint64
+	steps := []string{"θ", "ρπ", "χ", "ι"}
+	calls := 0
+	var last [25]uint64
+	KeccakF1600Trace(&lanes, func(round int, step string, l [25]uint64) {
+		if round != calls/4 || step != steps[calls%4] {
+			t.Fatalf("%d 回目の呼び出しがラウンド %d の %s です", calls, round, step)
+		}
+		// 1ラウンド目はθ、ρπ、χがゼロのままで、ιで RC[0] = 1 だけが入る
+		if round == 0 {
+			want := [25]uint64{}
+			if step == "ι" {
+				want[0] = 1
+			}
+			if l != want {
+				t.Errorf("ラウンド 0 の %s の後の状態が一致しません: %x", step, l)
+			}
+		}
+		calls++
+		last = l
+	})
+	if calls != 24*4 {
+		t.Fatalf("トレースの呼び出しが %d 回です (24ラウンド x 4ステップ)", calls)
+	}
+
+	// KeccakF-1600-IntermediateValues.txt の1回目の置換の結果
+	want := [25]uint64{
+		0xF1258F7940E1DDE7, 0x84D5CCF933C0478A, 0xD598261EA65AA9EE, 0xBD1547306F80494D, 0x8B284E056253D057,
+		0xFF97A42D7F8E6FD4, 0x90FEE5A0A44647C4, 0x8C5BDA0CD6192E76, 0xAD30A6F71B19059C, 0x30935AB7D08FFC64,
+		0xEB5AA93F2317D635, 0xA9A6E6260D712103, 0x81A57C16DBCF555F, 0x43B831CD0347C826, 0x01F22F1A11A5569F,
+		0x05E5635A21D9AE61, 0x64BEFEF28CC970F2, 0x613670957BC46611, 0xB87C5A554FD00ECB, 0x8C3EE88A1CCF32C8,
+		0x940C7922AE3A2614, 0x1841F924A2C509E4, 0x16F53526E70465C2, 0x75F644E97F30A13B, 0xEAF1FF7B5CECA249,
+	}
+	if lanes != want {
+		t.Errorf("置換の結果が公開値と一致しません: %x", lanes)
+	}
+	if last != lanes {
+		t.Error("最後のトレースの状態が置換の結果と一致しません")
+	}
+}
+
+// SHA3_TEST_MAIN が設定されていれば、テストの代わりにCLIとして動く (runCLIから起動する)
+func TestMain(m *testing.M) {
+	if os.Getenv("SHA3_TEST_MAIN") == "1" {
+		main()
+	}
+	os.Exit(m.Run())
+}
+
+// テストバイナリ自身をCLIとして起動し、標準出力、標準エラー、終了コードを返す
+func runCLI(t *testing.T, stdin string, args ...string) (stdout, stderr string, code int) {
+	t.Helper()
+	return runCLIEnv(t, nil, stdin, args...)
+}
+
+// runCLIに環境変数 ("名前=値") を追加して起動する
+// SHA3_ALGORITHM と SHA3_OUTPUT_LEN は、envで指定しない限り空にする
+func runCLIEnv(t *testing.T, env []string, stdin string, args ...string) (stdout, stderr string, code int) {
+	t.Helper()
+	cmd := exec.Command(os.Args[0], args...)
+	cmd.Env = append(os.Environ(), "SHA3_TEST_MAIN=1", "SHA3_ALGORITHM=", "SHA3_OUTPUT_LEN=")
+	cmd.Env = append(cmd.Env, env...)
+	cmd.Stdin = strings.NewReader(stdin)
+	var out, errOut bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &out, &errOut
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+	case errors.As(err, &exitErr):
+		code = exitErr.ExitCode()
+	default:
+		t.Fatal(err)
+	}
+	return out.String(), errOut.String(), code
+}
+
+// -o: 一部のファイルが開けなくても、残りの結果を書き込んでから入出力エラーで終了する
+func TestOutFileKeepsResultsOnInputFailure(t *testing.T) {
+	dir := t.TempDir()
+	present := filepath.Join(dir, "present")
+	if err := os.WriteFile(present, []byte("abc"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	missing := filepath.Join(dir, "missing")
+	list := filepath.Join(dir, "list")
+	if err := os.WriteFile(list, []byte(present+"\n"+missing+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	want := "3a985da74fe225b2045c172d6bd390bd855f086e3e9d525b46bfe24511431532  " + present
+
+	for _, tc := range []struct {
+		name  string
+		stdin string
+		args  []string
+	}{
+		{"args", "", []string{present, missing}},
+		{"nul", present + "\x00" + missing + "\x00", []string{"-0"}},
+		{"from-file", "", []string{"-from-file", list}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			out := filepath.Join(dir, tc.name+".txt")
+			_, stderr, code := runCLI(t, tc.stdin, append([]string{"-o", out}, tc.args...)...)
+			if code != exitIO {
+				t.Errorf("終了コード %d, want %d (stderr: %s)", code, exitIO, stderr)
+			}
+			got, err := os.ReadFile(out)
+			if err != nil {
+				t.Fatalf("出力ファイルが作られていません: %v", err)
+			}
+			if !strings.Contains(string(got), want) {
+				t.Errorf("出力 %q に %q が含まれていません", got, want)
+			}
+		})
+	}
+}
+
+// -o: 出力先に書き込めない場合は入力の失敗とは別のエラーになる
+func TestOutFileWriteFailure(t *testing.T) {
+	dir := t.TempDir()
+	present := filepath.Join(dir, "present")
+	if err := os.WriteFile(present, []byte("abc"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	_, stderr, code := runCLI(t, "", "-o", filepath.Join(dir, "no-such-dir", "out.txt"), present)
+	if code != exitIO {
+		t.Errorf("終了コード %d, want %d", code, exitIO)
+	}
+	if strings.Contains(stderr, errSomeInputsFailed.Error()) {
+		t.Errorf("書き込みの失敗が入力の失敗として報告されています: %s", stderr)
+	}
+}
+
+// TupleHash256のSP 800-185サンプル (#4〜#6、出力512ビット)
+func TestTupleHash256Samples(t *testing.T) {
+	x, y, z := seqBytes(0x00, 3), seqBytes(0x10, 6), seqBytes(0x20, 9)
+	for _, tc := range []struct {
+		tuple [][]byte
+		s     string
+		want  string
+	}{
+		{[][]byte{x, y}, "", "cfb7058caca5e668f81a12a20a2195ce97a925f1dba3e7449a56f82201ec607311ac2696b1ab5ea2352df1423bde7bd4bb78c9aed1a853c78672f9eb23bbe194"},
+		{[][]byte{x, y}, "My Tuple App", "147c2191d5ed7efd98dbd96d7ab5a11692576f5fe2a5065f3e33de6bba9f3aa1c4e9a068a289c61c95aab30aee1e410b0b607de3620e24a4e3bf9852a1d4367e"},
+		{[][]byte{x, y, z}, "My Tuple App", "45000be63f9b6bfd89f54717670f69a9bc763591a4f05c50d68891a744bcc6e7d6d5b5e82c018da999ed35b0bb49c9678e526abd8e85c13ed254021db9e790ce"},
+	} {
+		got := tupleHash256(tc.tuple, []byte(tc.s), 64)
+		if want := mustHex(t, tc.want); !bytes.Equal(got, want) {
+			t.Errorf("tupleHash256(%d要素, %q) = %x, want %x", len(tc.tuple), tc.s, got, want)
+		}
+	}
+}
+
+// SumTokens256 はトークン列のTupleHash256 (32バイト出力) と一致し、区切り方が違えば結果も変わる
+func TestSumTokens256(t *testing.T) {
+	sum := func(s string) []byte {
+		t.Helper()
+		sc := bufio.NewScanner(strings.NewReader(s))
+		sc.Split(bufio.ScanWords)
+		d, err := SumTokens256(sc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+	want := tupleHash256([][]byte{[]byte("ab"), []byte("c")}, nil, 32)
+	if got := sum("ab  c\n"); !bytes.Equal(got, want) {
+		t.Errorf("SumTokens256(\"ab c\") = %x, want %x", got, want)
+	}
+	if bytes.Equal(sum("ab c"), sum("a bc")) {
+		t.Error("区切り方が違うトークン列のダイジェストが一致しました")
+	}
+	if empty := tupleHash256(nil, nil, 32); !bytes.Equal(sum(""), empty) {
+		t.Errorf("空のトークン列 = %x, want %x", sum(""), empty)
+	}
+}
+
+// 1バイトずつ状態にXORする吸収 (レーン単位のxorLanesとの比較用)
+func xorBytes(s *state, block []byte) {
+	for j, c := range block {
+		s.a[j/8%5][j/8/5] ^= uint64(c) << uint(j%8*8)
+	}
+}
+
+func TestXorBytesMatchesLanes(t *testing.T) {
+	block := seqBytes(1, 136)
+	var byByte, byLane state
+	xorBytes(&byByte, block)
+	xorLanes(&byLane, block, 17)
+	if byByte != byLane {
+		t.Error("1バイトずつのXORとレーン単位のXORで状態が異なります")
+	}
+}
+
+// ベンチマークの入力 (固定のシードからSHAKE256で絞り出すので、どの環境でも同じバイト列になる)
+func benchInput(n int) []byte {
+	sp := newShake256()
+	sp.Write([]byte("sha3 benchmark input"))
+	b := make([]byte, n)
+	sp.Squeeze().Read(b)
+	return b
+}
+
+func TestBenchInput(t *testing.T) {
+	want := sha3.SumSHAKE256([]byte("sha3 benchmark input"), 1000)
+	if got := benchInput(1000); !bytes.Equal(got, want) {
+		t.Errorf("benchInput(1000) がcrypto/sha3のSHAKE256と一致しません")
+	}
+}
+
+// 吸収のベンチマークの入力サイズ
+var absorbSizes = []struct {
+	name string
+	size int
+}{{"1KB", 1 << 10}, {"1MB", 1 << 20}}
+
+// 吸収のみ (置換なし) のスループット: 1バイトずつとレーン単位をSHA3-256のレートで比べる
+func benchmarkAbsorb(b *testing.B, size int, xor func(*state, []byte)) {
+	const rate = 136
+	data := benchInput(size - size%rate)
+	b.SetBytes(int64(len(data)))
+	var s state
+	for i := 0; i < b.N; i++ {
+		for p := data; len(p) > 0; p = p[rate:] {
+			xor(&s, p[:rate])
+		}
+	}
+}
+
+func BenchmarkAbsorbBytewise(b *testing.B) {
+	for _, bs := range absorbSizes {
+		b.Run(bs.name, func(b *testing.B) { benchmarkAbsorb(b, bs.size, xorBytes) })
+	}
+}
+
+func BenchmarkAbsorbLanewise(b *testing.B) {
+	lanes := func(s *state, block []byte) { xorLanes(s, block, len(block)/8) }
+	for _, bs := range absorbSizes {
+		b.Run(bs.name, func(b *testing.B) { benchmarkAbsorb(b, bs.size, lanes) })
+	}
+}
+
+// 置換を何もしない関数に差し替えたスポンジのWrite (吸収経路全体から置換の費用を除いたもの)
+func BenchmarkAbsorbSpongeNoPermutation(b *testing.B) {
+	for _, bs := range absorbSizes {
+		b.Run(bs.name, func(b *testing.B) {
+			data := benchInput(bs.size)
+			sp := newSponge256()
+			sp.SetPermutation(func(*[25]uint64) {})
+			b.SetBytes(int64(bs.size))
+			for i := 0; i < b.N; i++ {
+				sp.Write(data)
+			}
+		})
+	}
+}
+
+// SetRate: 吸収前にSHA3-512のレートへ変えると、SHA3-512のダイジェストの先頭32バイトになる
+func TestSetRate(t *testing.T) {
+	msg := seqBytes(0, 300)
+	sp := newSponge256()
+	if err := sp.SetRate(576); err != nil {
+		t.Fatal(err)
+	}
+	sp.Write(msg)
+	want := sha3.Sum512(msg)
+	if got := sp.Sum(nil); !bytes.Equal(got, want[:32]) {
+		t.Errorf("レート576での32バイト出力 = %x, want %x", got, want[:32])
+	}
+
+	for _, rate := range []int{0, -8, 1604, 1004} {
+		if err := newSponge256().SetRate(rate); err == nil {
+			t.Errorf("SetRate(%d) がエラーになりません", rate)
+		}
+	}
+	sp = newSponge256()
+	sp.Write([]byte("partial"))
+	if err := sp.SetRate(576); err == nil {
+		t.Error("端数データが残っている状態でSetRateがエラーになりません")
+	}
+}
+
+// pad の不変条件: 長さはレートの倍数、先頭はメッセージのまま、最初のパディングバイトにドメインビット、
+// 最後のバイトに最上位ビットが立ち、その間は0
+func FuzzPad(f *testing.F) {
+	for _, n := range []int{0, 1, 134, 135, 136, 137, 271, 272, 273} {
+		f.Add(seqBytes(0, n), uint8(1))
+	}
+	rates := []int{1152, 1088, 832, 576, 1344} // SHA3-224/256/384/512、SHAKE128
+	f.Fuzz(func(t *testing.T, msg []byte, r uint8) {
+		rate := rates[int(r)%len(rates)]
+		orig := append([]byte(nil), msg...)
+		padded := pad(msg, rate)
+
+		if len(padded)%(rate/8) != 0 || len(padded) <= len(msg) {
+			t.Fatalf("len(pad(%d バイト, %d)) = %d", len(msg), rate, len(padded))
+		}
+		if !bytes.Equal(padded[:len(msg)], orig) || !bytes.Equal(msg, orig) {
+			t.Fatal("メッセージ部分が変わっています")
+		}
+		p := padded[len(msg):]
+		if p[0]&DomainSHA3 != DomainSHA3 {
+			t.Errorf("最初のパディングバイト %#02x にドメインビットがありません", p[0])
+		}
+		if p[len(p)-1]&0x80 == 0 {
+			t.Errorf("最後のバイト %#02x の最上位ビットが立っていません", p[len(p)-1])
+		}
+		if len(p) > 1 && !bytes.Equal(p[1:len(p)-1], make([]byte, len(p)-2)) {
+			t.Error("パディングの途中に0でないバイトがあります")
+		}
+	})
+}
+
+// 端数を含む1000バイトを新しいスポンジに吸収してSumIntoする (WriteNoCopyは端数をコピーしない)
+func benchmarkWrite(b *testing.B, write func(*Sponge, []byte)) {
+	msg := benchInput(1000)
+	var d [32]byte
+	b.ReportAllocs()
+	b.SetBytes(int64(len(msg)))
+	for i := 0; i < b.N; i++ {
+		sp := newSponge256()
+		write(sp, msg)
+		sp.SumInto(&d)
+	}
+}
+
+func BenchmarkWrite(b *testing.B) {
+	benchmarkWrite(b, func(sp *Sponge, p []byte) { sp.Write(p) })
+}
+
+func BenchmarkWriteNoCopy(b *testing.B) {
+	benchmarkWrite(b, (*Sponge).WriteNoCopy)
+}
+
+// パディング済みの複製を作る一括版 (比較用)
+func BenchmarkSha3_256Pad(b *testing.B) {
+	msg := benchInput(1000)
+	b.ReportAllocs()
+	b.SetBytes(int64(len(msg)))
+	for i := 0; i < b.N; i++ {
+		sha3_256(msg)
+	}
+}
+
+// WriteNoCopy は Write と同じダイジェストになり、端数を含めて割り当てをしない
+func TestWriteNoCopy(t *testing.T) {
+	msg := seqBytes(0, 1000)
+	sp := newSponge256()
+	sp.Write(msg)
+	want := sp.Sum(nil)
+
+	var d [32]byte
+	allocs := testing.AllocsPerRun(100, func() {
+		sp.Reset()
+		sp.WriteNoCopy(msg)
+		sp.SumInto(&d)
+	})
+	if !bytes.Equal(d[:], want) {
+		t.Errorf("WriteNoCopy = %x, want %x", d, want)
+	}
+	if allocs != 0 {
+		t.Errorf("WriteNoCopy+SumInto の割り当て %v 回, want 0", allocs)
+	}
+}
+
+// マスク生成関数をcrypto/sha3で組み立てた参照実装と比べる (32の倍数でない長さを含む)
+func TestMGF(t *testing.T) {
+	seed := []byte("mgf seed")
+	for _, n := range []int{0, 1, 31, 32, 33, 45, 100} {
+		want := make([]byte, n)
+		x := sha3.NewSHAKE256()
+		x.Write(seed)
+		x.Read(want)
+		if got := mgfSHAKE256(seed, n); !bytes.Equal(got, want) {
+			t.Errorf("mgfSHAKE256(%d) = %x, want %x", n, got, want)
+		}
+
+		var ref []byte
+		for c := byte(0); len(ref) < n; c++ {
+			d := sha3.Sum256(append(append([]byte(nil), seed...), 0, 0, 0, c))
+			ref = append(ref, d[:]...)
+		}
+		if got := mgf1Sha3_256(seed, n); !bytes.Equal(got, ref[:n]) {
+			t.Errorf("mgf1Sha3_256(%d) = %x, want %x", n, got, ref[:n])
+		}
+	}
+
+	// 固定の既知解 (Pythonのhashlibで計算した45バイト)
+	for _, tc := range []struct {
+		name string
+		mgf  func([]byte, int) []byte
+		want string
+	}{
+		{"mgfSHAKE256", mgfSHAKE256, "8d4e9414b590f32bf0b51b71c9d606e8fe467180b1bf71c312c7b423f3c94f08615066e5c3e6652ec6943ba05e"},
+		{"mgf1Sha3_256", mgf1Sha3_256, "5ecbad287899a1b0bb662c0fc60349fbe823843f7d6db9223dc9b134d01b1ccd78d23f361e5efa95eb95184080"},
+	} {
+		if got := tc.mgf(seed, 45); !bytes.Equal(got, mustHex(t, tc.want)) {
+			t.Errorf("%s(%q, 45) = %x, want %s", tc.name, seed, got, tc.want)
+		}
+	}
+}
+
+// NameUUID: バージョン8とバリアント10xxのビット、および固定の名前に対する値
+// (名前空間はRFC 9562のDNS、期待値はPythonのhashlibとuuidで計算)
+func TestNameUUID(t *testing.T) {
+	dns := mustHex(t, "6ba7b8109dad11d180b400c04fd430c8")
+	u := NameUUID(dns, []byte("www.example.com"))
+	if got, want := hex.EncodeToString(u[:]), "10e1aa774f8c8a0fbe35354cc01a76ac"; got != want {
+		t.Errorf("NameUUID(DNS, www.example.com) = %s, want %s", got, want)
+	}
+
+	for _, name := range []string{"", "a", "www.example.org", strings.Repeat("x", 300)} {
+		u := NameUUID(dns, []byte(name))
+		if u[6]>>4 != 8 {
+			t.Errorf("NameUUID(%q) のバージョン %d, want 8", name, u[6]>>4)
+		}
+		if u[8]>>6 != 0b10 {
+			t.Errorf("NameUUID(%q) のバリアント %02b, want 10", name, u[8]>>6)
+		}
+		if u != NameUUID(dns, []byte(name)) {
+			t.Errorf("NameUUID(%q) が決定的ではありません", name)
+		}
+	}
+}
+
+// sumPrefixed256Reader はシークできるリーダーでもできないリーダーでも sumPrefixed256 と一致する
+func TestSumPrefixed256Reader(t *testing.T) {
+	for _, n := range []int{0, 1, 135, 136, 137, 1000} {
+		data := seqBytes(7, n)
+		want := sumPrefixed256(data)
+		for name, r := range map[string]io.Reader{
+			"seeker":    bytes.NewReader(data),
+			"nonseeker": iotest.OneByteReader(bytes.NewReader(data)),
+		} {
+			got, err := sumPrefixed256Reader(r)
+			if err != nil {
+				t.Fatalf("%s %d バイト: %v", name, n, err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("%s %d バイト = %x, want %x", name, n, got, want)
+			}
+		}
+	}
+
+	// シークできるリーダーは現在位置から末尾までを対象にする
+	data := seqBytes(0, 500)
+	r := bytes.NewReader(data)
+	r.Seek(100, io.SeekStart)
+	got, err := sumPrefixed256Reader(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := sumPrefixed256(data[100:]); !bytes.Equal(got, want) {
+		t.Errorf("途中の位置から = %x, want %x", got, want)
+	}
+}
+
+// xorLanes の高速経路 (8バイト境界に揃ったブロック) と移植版が、揃っていない位置も含めて一致する
+func TestXorLanesMatchesGeneric(t *testing.T) {
+	buf := seqBytes(0x5a, 25*8+8)
+	for off := 0; off < 8; off++ {
+		for lanes := 0; lanes <= 25; lanes++ {
+			block := buf[off : off+lanes*8]
+			var fast, generic state
+			fast.a[2][3], generic.a[2][3] = 0x0123456789abcdef, 0x0123456789abcdef
+			xorLanes(&fast, block, lanes)
+			xorLanesGeneric(&generic, block, lanes)
+			if fast != generic {
+				t.Errorf("オフセット %d、%d レーンで結果が異なります", off, lanes)
+			}
+		}
+	}
+}
+
+// レート境界前後の長さで、揃った入力と揃っていない入力のどちらもcrypto/sha3と一致する
+func TestSum256AlignmentAroundRate(t *testing.T) {
+	buf := seqBytes(1, 3*136+9)
+	for _, n := range []int{0, 1, 7, 8, 9, 135, 136, 137, 271, 272, 273, 3 * 136} {
+		for off := 0; off < 9; off++ {
+			msg := buf[off : off+n]
+			want := sha3.Sum256(msg)
+			sp := newSponge256()
+			sp.Write(msg)
+			if got := sp.Sum(nil); !bytes.Equal(got, want[:]) {
+				t.Errorf("%d バイト (オフセット %d) = %x, want %x", n, off, got, want)
+			}
+		}
+	}
+}
+
+// SHA3-256のスループット (SHAKEで作った入力)
+func BenchmarkSum256(b *testing.B) {
+	for _, bs := range absorbSizes {
+		b.Run(bs.name, func(b *testing.B) {
+			data := benchInput(bs.size)
+			b.SetBytes(int64(bs.size))
+			for i := 0; i < b.N; i++ {
+				sp := newSponge256()
+				sp.Write(data)
+				sp.Sum(nil)
+			}
+		})
+	}
+}
+
+// Permutations: 吸収したブロック数だけ増え、Sum/SumInto/Squeezeでは変わらず、Resetで0に戻る
+func TestPermutations(t *testing.T) {
+	sp := newSponge256()
+	sp.Write(benchInput(3*136 + 10))
+	if got := sp.Permutations(); got != 3 {
+		t.Fatalf("3ブロック吸収後 = %d, want 3", got)
+	}
+
+	var d [32]byte
+	for i := 0; i < 3; i++ {
+		sp.Sum(nil)
+		sp.SumInto(&d)
+		sp.Squeeze()
+		if got := sp.Permutations(); got != 3 {
+			t.Fatalf("%d 回目のSum後 = %d, want 3", i+1, got)
+		}
+	}
+
+	// 最後のブロックの置換と、レートを超えて絞り出した分は複製側で数える
+	sq := sp.Squeeze()
+	if got := sq.Permutations(); got != 4 {
+		t.Errorf("Squeeze直後 = %d, want 4", got)
+	}
+	sq.Read(make([]byte, 2*136+1))
+	if got := sq.Permutations(); got != 6 {
+		t.Errorf("%d バイト絞り出した後 = %d, want 6", 2*136+1, got)
+	}
+
+	sp.Reset()
+	if got := sp.Permutations(); got != 0 {
+		t.Errorf("Reset後 = %d, want 0", got)
+	}
+}
+
+// HMAC-SHA3-256をcrypto/hmacとcrypto/sha3と比べる (レートより長い鍵を含め、鍵のバッファは書き換えない)
+func TestHMACSHA3_256(t *testing.T) {
+	newRef := func() hash.Hash { return sha3.New256() }
+	msg := []byte("The quick brown fox jumps over the lazy dog")
+	for _, n := range []int{0, 32, 135, 136, 137, 200} {
+		buf := make([]byte, n, n+200)
+		copy(buf, seqBytes(0x10, n))
+		key := buf[:n]
+
+		ref := hmac.New(newRef, key)
+		ref.Write(msg)
+		want := ref.Sum(nil)
+		if got := hmacSHA3_256(key, msg); !bytes.Equal(got, want) {
+			t.Errorf("鍵 %d バイト = %x, want %x", n, got, want)
+		}
+		if spare := buf[n : n+200]; !bytes.Equal(spare, make([]byte, 200)) {
+			t.Errorf("鍵 %d バイト: 呼び出し側のバッファの余り領域が書き換えられました", n)
+		}
+	}
+}
+
+// ストリーミングのHMACを不揃いな大きさで数MB書き込んでも一括計算と一致し、Resetで最初からやり直せる
+func TestHMACStreaming(t *testing.T) {
+	key := seqBytes(0, 150)
+	data := benchInput(3<<20 + 17)
+	want := hmacSHA3_256(key, data)
+
+	d := newHMAC256(key)
+	for i := 0; i < 2; i++ {
+		for p, n := data, 1; len(p) > 0; n = n*7%9973 + 1 {
+			n = min(n, len(p))
+			d.Write(p[:n])
+			p = p[n:]
+		}
+		if got := d.Sum(nil); !bytes.Equal(got, want) {
+			t.Errorf("%d 回目のストリーミング = %x, want %x", i+1, got, want)
+		}
+		d.Reset()
+	}
+}
+
+// モンテカルロテストのチェックポイント (公式の応答ファイルはオフラインで使えないため、
+// 同じ手順をPythonのhashlibで計算した値と、crypto/sha3で繰り返した値の両方と比べる)
+func TestMonteCarlo256(t *testing.T) {
+	buf := make([]byte, 32, 64)
+	copy(buf, mustHex(t, "aa64f7245e2177c654eb4de360da8761a516fdc7578c3498c5e582e096b8730c"))
+	seed := buf[:32]
+
+	got := monteCarlo256(seed, 5)
+	if len(got) != 5 {
+		t.Fatalf("チェックポイントの数 %d, want 5", len(got))
+	}
+	for j, want := range []string{
+		"225cbac2be6f329d94228c5360a1c177bc495a761c442a1771b1d18555c309a5",
+		"96d364a1b1ced3dbbce6380093fb1ac77221abcee30faf16546ffad8fe1eef8c",
+		"8d81a67598ff73e2305ed53b1e6d58c799a1d1908abf81a15eab4bfd35b96e51",
+	} {
+		if hex.EncodeToString(got[j]) != want {
+			t.Errorf("チェックポイント %d = %x, want %s", j, got[j], want)
+		}
+	}
+
+	var md [32]byte
+	copy(md[:], seed)
+	for j := range got {
+		for i := 0; i < 1000; i++ {
+			md = sha3.Sum256(md[:])
+		}
+		if !bytes.Equal(got[j], md[:]) {
+			t.Errorf("チェックポイント %d がcrypto/sha3と一致しません", j)
+		}
+	}
+	if !bytes.Equal(buf[32:64], make([]byte, 32)) {
+		t.Error("呼び出し側のシードのバッファの余り領域が書き換えられました")
+	}
+}
+
+// ブロック境界 (レート136バイト) の直前・ちょうど・直後の長さを、一括・1バイトずつ・SumInto・
+// バッファ再利用の各経路でcrypto/sha3と比べる。136バイトではパディングだけのブロックが増える
+func TestBlockBoundary(t *testing.T) {
+	for _, tc := range []struct {
+		n     int
+		perms int // 最後のブロックまでの置換の回数
+	}{{135, 1}, {136, 2}, {137, 2}} {
+		msg := benchInput(tc.n)
+		want := sha3.Sum256(msg)
+
+		if got := sha3_256(msg); !bytes.Equal(got, want[:]) {
+			t.Errorf("sha3_256(%d バイト) = %x, want %x", tc.n, got, want)
+		}
+
+		sp := newSponge256()
+		for i := range msg {
+			sp.Write(msg[i : i+1])
+		}
+		if got := sp.Sum(nil); !bytes.Equal(got, want[:]) {
+			t.Errorf("1バイトずつ %d バイト = %x, want %x", tc.n, got, want)
+		}
+		var d [32]byte
+		sp.SumInto(&d)
+		if d != want {
+			t.Errorf("SumInto %d バイト = %x, want %x", tc.n, d, want)
+		}
+		if got := sp.Squeeze().Permutations(); got != tc.perms {
+			t.Errorf("%d バイトの置換の回数 = %d, want %d", tc.n, got, tc.perms)
+		}
+
+		buf := make([]byte, tc.n, 2*136)
+		copy(buf, msg)
+		if got := sha3_256InPlace(buf); !bytes.Equal(got, want[:]) {
+			t.Errorf("sha3_256InPlace(%d バイト) = %x, want %x", tc.n, got, want)
+		}
+	}
+}
+
+// 手入力のRCとrが、gentables.go がFIPS 202の定義から計算した値と一致する
+func TestGeneratedTables(t *testing.T) {
+	if len(RC) != len(generatedRC) {
+		t.Fatalf("len(RC) = %d, want %d", len(RC), len(generatedRC))
+	}
+	for i, c := range generatedRC {
+		if RC[i] != c {
+			t.Errorf("ラウンド定数 %d = %016x, want %016x", i, RC[i], c)
+		}
+	}
+	for x := range generatedRho {
+		for y, off := range generatedRho[x] {
+			if r[x][y] != off {
+				t.Errorf("回転オフセット r[%d][%d] = %d, want %d", x, y, r[x][y], off)
+			}
+		}
+	}
+}
+
+// MerkleRoot を1〜4枚の葉で、crypto/sha3で手で組み立てた木と比べる
+func TestMerkleRoot(t *testing.T) {
+	h := func(parts ...[]byte) []byte {
+		d := sha3.Sum256(bytes.Join(parts, nil))
+		return d[:]
+	}
+	leaf := func(b []byte) []byte { return h([]byte{0x00}, b) }
+	node := func(l, r []byte) []byte { return h([]byte{0x01}, l, r) }
+	a, b, c, d := []byte("a"), []byte("b"), []byte("c"), []byte("d")
+
+	for _, tc := range []struct {
+		leaves [][]byte
+		want   []byte
+	}{
+		{nil, h()},
+		{[][]byte{a}, leaf(a)},
+		{[][]byte{a, b}, node(leaf(a), leaf(b))},
+		{[][]byte{a, b, c}, node(node(leaf(a), leaf(b)), leaf(c))},
+		{[][]byte{a, b, c, d}, node(node(leaf(a), leaf(b)), node(leaf(c), leaf(d)))},
+	} {
+		if got := MerkleRoot(tc.leaves); !bytes.Equal(got, tc.want) {
+			t.Errorf("MerkleRoot(%d枚) = %x, want %x", len(tc.leaves), got, tc.want)
+		}
+	}
+}
+
+// 葉の区切りをずらした入力、内部ノードを葉として渡した入力、最後の葉を複製した入力は別のルートになる
+func TestMerkleRootSeparation(t *testing.T) {
+	x, y, z := []byte("x"), []byte("y"), []byte("z")
+	inner := sha3.Sum256(append([]byte{0x01}, append(merkleLeaf(x), merkleLeaf(y)...)...))
+	for _, pair := range [][2][][]byte{
+		{{[]byte("ab"), []byte("c")}, {[]byte("a"), []byte("bc")}},
+		{{x, y, z}, {inner[:], z}},
+		{{x, y, z}, {x, y, z, z}},
+		{{x}, {merkleLeaf(x)}},
+	} {
+		if bytes.Equal(MerkleRoot(pair[0]), MerkleRoot(pair[1])) {
+			t.Errorf("%q と %q のルートが一致しました", pair[0], pair[1])
+		}
+	}
+}
+
+// 葉のハッシュ SHA3-256(0x00 || b)
+func merkleLeaf(b []byte) []byte {
+	d := sha3.Sum256(append([]byte{0x00}, b...))
+	return d[:]
+}
+
+// Accumulator のルートは tupleHash256(ダイジェスト列, nil, 32) と等しく、Rootは状態を変えない
+func TestAccumulator(t *testing.T) {
+	acc := NewAccumulator()
+	var digests [][]byte
+	for i := 0; i < 4; i++ {
+		d := sha3.Sum256([]byte{byte(i)})
+		acc.Add(d)
+		digests = append(digests, d[:])
+
+		want := tupleHash256(digests, nil, 32)
+		for j := 0; j < 2; j++ {
+			if got := acc.Root(); !bytes.Equal(got, want) {
+				t.Errorf("%d個追加後のRoot = %x, want %x", i+1, got, want)
+			}
+		}
+		if acc.Len() != i+1 {
+			t.Errorf("Len() = %d, want %d", acc.Len(), i+1)
+		}
+	}
+}
+
+// go test -run Golden -update でゴールデンファイルを書き直す
+var updateGolden = flag.Bool("update", false, "testdata/golden のゴールデンファイルを現在の出力で書き直す")
+
+// gotをゴールデンファイル testdata/golden/name.golden と比べる
+func checkGolden(t *testing.T, name, got string) {
+	t.Helper()
+	path := filepath.Join("testdata", "golden", name+".golden")
+	if *updateGolden {
+		if err := os.WriteFile(path, []byte(got), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != string(want) {
+		t.Errorf("%s の出力が一致しません\n--- got\n%s--- want\n%s", path, got, want)
+	}
+}
+
+// formatResult の表示形式ごとの出力
+func TestFormatResultGolden(t *testing.T) {
+	digest := sha3.Sum256([]byte("abc"))
+	var b strings.Builder
+	for _, tc := range []struct {
+		name string
+		opts formatOpts
+	}{
+		{"hex", formatOpts{encode: hex.EncodeToString}},
+		{"hex-sumline", formatOpts{encode: hex.EncodeToString, sumLine: true}},
+		{"multihash-base58-sumline", formatOpts{encode: func(d []byte) string { return base58Encode(multihash(0x16, d)) }, sumLine: true}},
+		{"decimal-sumline", formatOpts{encode: decimalString, sumLine: true}},
+		{"group-sumline", formatOpts{encode: func(d []byte) string { return groupString(hex.EncodeToString(d), 8) }, sumLine: true}},
+	} {
+		fmt.Fprintf(&b, "# %s\n", tc.name)
+		b.WriteString(formatResult("SHA3-256", "abc", digest[:], tc.opts))
+		b.WriteString(formatResult("SHA3-256", "a\\b\nc", digest[:], tc.opts))
+	}
+	checkGolden(t, "format_result", b.String())
+}
+
+// CLIの表示形式ごとの出力 (標準出力と終了コード)
+func TestCLIGolden(t *testing.T) {
+	input := filepath.Join("testdata", "input.txt")
+	for _, tc := range []struct {
+		name  string
+		stdin string
+		args  []string
+	}{
+		{"sumline", "", []string{"-s", "abc"}},
+		{"multihash-hex", "", []string{"-multihash", "hex", "-s", "abc"}},
+		{"multihash-base58", "", []string{"-multihash", "base58", "-s", "abc"}},
+		{"decimal", "", []string{"-decimal", "-s", "abc"}},
+		{"group", "", []string{"-group", "8", "-s", "abc"}},
+		{"file", "", []string{input}},
+		{"json", "", []string{"-json", input}},
+		{"shake256", "", []string{"-algorithm", "shake256:16", "-s", "abc"}},
+		{"interactive", "abc\n\nq\n", nil},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			stdout, stderr, code := runCLI(t, tc.stdin, tc.args...)
+			if code != exitOK {
+				t.Fatalf("終了コード %d (stderr: %s)", code, stderr)
+			}
+			checkGolden(t, "cli_"+tc.name, stdout)
+		})
+	}
+}
+
+// -chunked: 各行はチャンクのSHA3-256で、行のダイジェストを連結してハッシュし直すとルートになる
+func TestHashChunked(t *testing.T) {
+	fh := &fileHasher{newHash: func() hash.Hash { return newSponge256() }, encode: hex.EncodeToString}
+	const chunkSize = 1000
+	for _, n := range []int{0, 1, chunkSize, 2*chunkSize + 500} {
+		data := benchInput(n)
+		var out bytes.Buffer
+		if err := fh.hashChunked(bytes.NewReader(data), &out, chunkSize); err != nil {
+			t.Fatal(err)
+		}
+		lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+		if want := (n + chunkSize - 1) / chunkSize; len(lines) != want+1 {
+			t.Fatalf("%d バイト: %d 行, want チャンク %d 行 + ルート", n, len(lines), want)
+		}
+
+		var joined []byte
+		for i, line := range lines[:len(lines)-1] {
+			chunk := data[i*chunkSize : min((i+1)*chunkSize, n)]
+			want := sha3.Sum256(chunk)
+			if d := mustHex(t, line); !bytes.Equal(d, want[:]) {
+				t.Errorf("%d バイト: チャンク %d = %s, want %x", n, i, line, want)
+			}
+			joined = append(joined, mustHex(t, line)...)
+		}
+		if root := sha3.Sum256(joined); lines[len(lines)-1] != hex.EncodeToString(root[:]) {
+			t.Errorf("%d バイト: ルート %s, want %x", n, lines[len(lines)-1], root)
+		}
+	}
+}
+
+// AbsorbBlock: メッセージを自前でパディングしてブロックごとに吸収し、状態の先頭32バイトを取り出すと
+// SHA3-256になる。完全なブロックをAbsorbBlockし、残りをWriteしてSumしても同じになる
+func TestAbsorbBlock(t *testing.T) {
+	for _, n := range []int{0, 135, 136, 300} {
+		msg := benchInput(n)
+		want := sha3.Sum256(msg)
+
+		sp := newSponge256()
+		padded := append(append([]byte(nil), msg...), Pad101(n*8, RATE, DomainSHA3)...)
+		for p := padded; len(p) > 0; p = p[136:] {
+			sp.AbsorbBlock(p[:136])
+		}
+		var got [32]byte
+		extractLanes(&sp.s, got[:])
+		if got != want {
+			t.Errorf("%d バイト: 手動のパディング = %x, want %x", n, got, want)
+		}
+
+		sp = newSponge256()
+		full := n - n%136
+		for p := msg[:full]; len(p) > 0; p = p[136:] {
+			sp.AbsorbBlock(p[:136])
+		}
+		sp.Write(msg[full:])
+		if got := sp.Sum(nil); !bytes.Equal(got, want[:]) {
+			t.Errorf("%d バイト: AbsorbBlock+Write = %x, want %x", n, got, want)
+		}
+	}
+
+	mustPanic := func(name string, f func()) {
+		t.Helper()
+		defer func() {
+			if recover() == nil {
+				t.Errorf("%s がpanicしません", name)
+			}
+		}()
+		f()
+	}
+	mustPanic("レートと異なる長さ", func() { newSponge256().AbsorbBlock(make([]byte, 135)) })
+	mustPanic("端数データが残った状態", func() {
+		sp := newSponge256()
+		sp.Write([]byte("x"))
+		sp.AbsorbBlock(make([]byte, 136))
+	})
+}
+
+// 終了コード: 0 成功、2 フラグや引数の誤り、3 照合の不一致、4 入出力エラー
+func TestExitCodes(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	x, y, x2 := write("x", "x"), write("y", "y"), write("x2", "x")
+	manifest, _, _ := runCLI(t, "", x)
+	good := write("good.sha3", manifest)
+	bad := write("bad.sha3", strings.Repeat("0", 64)+manifest[64:])
+	missing := filepath.Join(dir, "missing")
+
+	for _, tc := range []struct {
+		name string
+		args []string
+		want int
+	}{
+		{"文字列", []string{"-s", "abc"}, exitOK},
+		{"ファイル", []string{x, y}, exitOK},
+		{"照合の一致", []string{"-c", good}, exitOK},
+		{"cmpの一致", []string{"-cmp", x, x2}, exitOK},
+		{"照合の不一致", []string{"-c", bad}, exitMismatch},
+		{"cmpの不一致", []string{"-cmp", x, y}, exitMismatch},
+		{"未定義のフラグ", []string{"-no-such-flag"}, exitUsage},
+		{"未対応のアルゴリズム", []string{"-algorithm", "md5", "-s", "x"}, exitUsage},
+		{"負の-head", []string{"-head", "-1", x}, exitUsage},
+		{"存在しないファイル", []string{x, missing}, exitIO},
+		{"存在しないマニフェスト", []string{"-c", missing}, exitIO},
+		{"存在しない一覧", []string{"-from-file", missing}, exitIO},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, stderr, code := runCLI(t, "", tc.args...)
+			if code != tc.want {
+				t.Errorf("sha3 %s の終了コード %d, want %d (stderr: %s)", strings.Join(tc.args, " "), code, tc.want, stderr)
+			}
+		})
+	}
+}
+
+// exitCode: エラーの種類ごとの終了コード (スクリプトが分岐に使うので値そのものを固定する)
+func TestExitCode(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"成功", nil, 0},
+		{"フラグや引数の誤り", usageError("bad"), 2},
+		{"照合の不一致", &cliError{kind: ErrVerifyFailed, err: errors.New("mismatch")}, 3},
+		{"入出力エラー", &cliError{kind: ErrOpen, err: os.ErrNotExist}, 4},
+		{"ラップされた不一致", fmt.Errorf("manifest: %w", &cliError{kind: ErrVerifyFailed, err: errors.New("mismatch")}), 3},
+		{"一部の入力の失敗", errSomeInputsFailed, 4},
+		{"種類のないエラー", errors.New("other"), 4},
+	} {
+		if got := exitCode(tc.err); got != tc.want {
+			t.Errorf("%s: exitCode = %d, want %d", tc.name, got, tc.want)
+		}
+	}
+}
+
+// sum256WithState: 同じ状態を別のメッセージに使い回しても、前の計算の値が残らない
+func TestSum256WithState(t *testing.T) {
+	var s state
+	s.a[1][2] = 0xdeadbeef // 汚れた状態から始めても結果に影響しない
+	for _, msg := range [][]byte{[]byte("abc"), benchInput(300), nil, benchInput(136), []byte("abc")} {
+		want := sha3.Sum256(msg)
+		if got := sum256WithState(&s, msg); !bytes.Equal(got, want[:]) {
+			t.Errorf("%d バイト = %x, want %x", len(msg), got, want)
+		}
+	}
+}
+
+// 複数のゴルーチンからConcurrentHasher、ParallelHash256、共有したスポンジのSum/SumInto/Squeeze/Clone、
+// 複製ごとのWrite/Resetを同時に呼ぶ (go test -race で競合がないことを確認する)
+func TestConcurrentUse(t *testing.T) {
+	data := benchInput(10000)
+	want := sha3.Sum256(data)
+	wantParallel, err := ParallelHash256(data, 1024, nil, 32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	shared := newSponge256()
+	shared.Write(data)
+
+	var ch ConcurrentHasher
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 20; i++ {
+				if got := ch.Sum256(data); !bytes.Equal(got, want[:]) {
+					t.Error("ConcurrentHasher.Sum256 が一致しません")
+				}
+				if got, _ := ParallelHash256(data, 1024, nil, 32); !bytes.Equal(got, wantParallel) {
+					t.Error("ParallelHash256 が一致しません")
+				}
+
+				var d [32]byte
+				shared.SumInto(&d)
+				out := make([]byte, 32)
+				shared.Squeeze().Read(out)
+				if got := shared.Sum(nil); !bytes.Equal(got, want[:]) || d != want || !bytes.Equal(out, want[:]) {
+					t.Error("共有したスポンジのSumが一致しません")
+				}
+
+				c := shared.Clone()
+				c.Write(data[:i])
+				c.Reset()
+				c.Write(data)
+				if got := c.Sum(nil); !bytes.Equal(got, want[:]) {
+					t.Error("複製のReset後のSumが一致しません")
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if got := shared.Permutations(); got != len(data)/136 {
+		t.Errorf("共有したスポンジの置換の回数 %d, want %d", got, len(data)/136)
+	}
+}
+
+// 1回の読み込みで最大7バイトしか返さないReaderAt
+type shortReaderAt struct{ b []byte }
+
+func (r shortReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	if off >= int64(len(r.b)) {
+		return 0, io.EOF
+	}
+	n := copy(p[:min(len(p), 7)], r.b[off:])
+	return n, nil
+}
+
+// 常にerrを返すReaderAt
+type errReaderAt struct{ err error }
+
+func (r errReaderAt) ReadAt([]byte, int64) (int, error) { return 0, r.err }
+
+// Sum256Range: 範囲のダイジェスト、短い読み込み、入力の終わりを超える範囲と読み込みエラー
+func TestSum256Range(t *testing.T) {
+	data := benchInput(1000)
+	path := filepath.Join(t.TempDir(), "data")
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	for name, r := range map[string]io.ReaderAt{"bytes": bytes.NewReader(data), "file": f, "short": shortReaderAt{data}} {
+		for _, rg := range [][2]int64{{0, 1000}, {100, 300}, {999, 1}, {1000, 0}, {0, 0}} {
+			off, length := rg[0], rg[1]
+			want := sha3.Sum256(data[off : off+length])
+			got, err := Sum256Range(r, off, length)
+			if err != nil || !bytes.Equal(got, want[:]) {
+				t.Errorf("%s [%d, %d) = %x, %v, want %x", name, off, off+length, got, err, want)
+			}
+		}
+		for _, rg := range [][2]int64{{0, 1001}, {990, 20}, {2000, 1}, {-1, 10}, {0, -1}} {
+			if _, err := Sum256Range(r, rg[0], rg[1]); err == nil {
+				t.Errorf("%s オフセット %d、長さ %d がエラーになりません", name, rg[0], rg[1])
+			}
+		}
+	}
+
+	errRead := errors.New("read failed")
+	if _, err := Sum256Range(errReaderAt{errRead}, 0, 10); !errors.Is(err, errRead) {
+		t.Errorf("読み込みエラー = %v, want %v", err, errRead)
+	}
+}
+
+// VerifyFramed256: 一致、不一致、32バイトに満たない入力、ペイロードの読み込みエラー
+func TestVerifyFramed256(t *testing.T) {
+	payload := benchInput(500)
+	digest := sha3.Sum256(payload)
+	framed := append(digest[:], payload...)
+	empty := sha3.Sum256(nil)
+	tamperedPayload := bytes.Clone(framed)
+	tamperedPayload[len(framed)-1] ^= 1
+	tamperedDigest := bytes.Clone(framed)
+	tamperedDigest[0] ^= 1
+
+	for _, tc := range []struct {
+		name string
+		r    io.Reader
+		want bool
+	}{
+		{"一致", bytes.NewReader(framed), true},
+		{"1バイトずつ読んで一致", iotest.OneByteReader(bytes.NewReader(framed)), true},
+		{"空のペイロード", bytes.NewReader(empty[:]), true},
+		{"ペイロードの改変", bytes.NewReader(tamperedPayload), false},
+		{"ダイジェストの改変", bytes.NewReader(tamperedDigest), false},
+	} {
+		ok, err := VerifyFramed256(tc.r)
+		if err != nil || ok != tc.want {
+			t.Errorf("%s = %t, %v, want %t", tc.name, ok, err, tc.want)
+		}
+	}
+
+	for _, n := range []int{0, 1, 31} {
+		if _, err := VerifyFramed256(bytes.NewReader(framed[:n])); err == nil {
+			t.Errorf("%d バイトの入力がエラーになりません", n)
+		}
+	}
+	errRead := errors.New("read failed")
+	r := io.MultiReader(bytes.NewReader(framed[:40]), iotest.ErrReader(errRead))
+	if _, err := VerifyFramed256(r); !errors.Is(err, errRead) {
+		t.Errorf("読み込みエラー = %v, want %v", err, errRead)
+	}
+}
+
+// sum256Personalized をcrypto/sha3のcSHAKE256と比べ、personalizationごとに結果が分かれることを確認する
+func TestSum256Personalized(t *testing.T) {
+	for _, n := range []int{0, 3, 136, 300} {
+		data := benchInput(n)
+		for _, p := range []string{"", "Email Signature", strings.Repeat("p", 200)} {
+			ref := sha3.NewCSHAKE256(nil, []byte(p))
+			ref.Write(data)
+			want := make([]byte, 32)
+			ref.Read(want)
+			if got := sum256Personalized(data, []byte(p)); !bytes.Equal(got, want) {
+				t.Errorf("%d バイト、%q = %x, want %x", n, p, got, want)
+			}
+		}
+
+		if want := sha3.SumSHAKE256(data, 32); !bytes.Equal(sum256Personalized(data, nil), want) {
+			t.Errorf("%d バイト: 空のpersonalizationがSHAKE256の32バイト出力と一致しません", n)
+		}
+		if bytes.Equal(sum256Personalized(data, []byte("a")), sum256Personalized(data, []byte("b"))) {
+			t.Errorf("%d バイト: 異なるpersonalizationのダイジェストが一致しました", n)
+		}
+	}
+}
+
+// Keccak-f[1600]の各ステップが全単射であることを確かめる
+//   - θとρπを合わせた線形層は、1600個の単位ベクトルの像がGF(2)上で一次独立 (階数1600)
+//   - χは行 (5ビット) ごとの写像で、32通りの入力がすべて異なる出力になる
+//   - ιは定数のXORなので自明に全単射
+func TestKeccakStepsBijective(t *testing.T) {
+	var basis [1600]*[25]uint64 // 最上位のビット位置ごとの基底
+	for i := 0; i < 1600; i++ {
+		var s state
+		s.a[i/64%5][i/64/5] = 1 << uint(i%64)
+		s.theta()
+		s.rhoPi()
+		v := new([25]uint64)
+		s.toLanes(v)
+		for {
+			top := -1
+			for w := 24; w >= 0; w-- {
+				if v[w] != 0 {
+					top = w*64 + 63 - bits.LeadingZeros64(v[w])
+					break
+				}
+			}
+			if top < 0 {
+				t.Fatalf("θ∘ρπ: 単位ベクトル %d の像が一次従属です", i)
+			}
+			if basis[top] == nil {
+				basis[top] = v
+				break
+			}
+			for w := range v {
+				v[w] ^= basis[top][w]
+			}
+		}
+	}
+
+	seen := make(map[[5]uint64]int)
+	for v := 0; v < 32; v++ {
+		var s state
+		for x := 0; x < 5; x++ {
+			s.a[x][2] = uint64(v>>x&1) << 17
+		}
+		s.chi()
+		var row [5]uint64
+		for x := range row {
+			row[x] = s.a[x][2]
+		}
+		if prev, ok := seen[row]; ok {
+			t.Fatalf("χ: 行 %05b と %05b が同じ出力になります", prev, v)
+		}
+		seen[row] = v
+	}
+}
+
+// 多数の異なる状態にKeccak-f[1600]を2回適用しても出力が衝突しない
+// (SHAKE256で作った乱数的な状態と、1つの状態の各ビットを反転した1600個の状態)
+func TestKeccakF1600NoCollisions(t *testing.T) {
+	var inputs [][25]uint64
+	stream := newShake256()
+	stream.Write([]byte("keccak-f property test"))
+	sq := stream.Squeeze()
+	buf := make([]byte, 200)
+	for i := 0; i < 2000; i++ {
+		sq.Read(buf)
+		var l [25]uint64
+		for j := range l {
+			l[j] = binary.LittleEndian.Uint64(buf[j*8:])
+		}
+		inputs = append(inputs, l)
+	}
+	base := inputs[0]
+	for i := 0; i < 1600; i++ {
+		l := base
+		l[i/64] ^= 1 << uint(i%64)
+		inputs = append(inputs, l)
+	}
+
+	seen := make(map[[25]uint64]int, len(inputs))
+	for i, l := range inputs {
+		KeccakF1600(&l)
+		KeccakF1600(&l)
+		if j, ok := seen[l]; ok {
+			t.Fatalf("入力 %d と %d の出力が一致しました", j, i)
+		}
+		seen[l] = i
+	}
+}
+
+// 割り当てをしない確定処理: SumIntoとConcurrentHasher.Sum256Intoは (プールが温まった後は) 0回
+func TestSumIntoAllocs(t *testing.T) {
+	data := benchInput(1000)
+	want := sha3.Sum256(data)
+	sp := newSponge256()
+	sp.Write(data)
+	var ch ConcurrentHasher
+	var d [32]byte
+
+	if n := testing.AllocsPerRun(100, func() { sp.SumInto(&d) }); n != 0 || d != want {
+		t.Errorf("SumInto: 割り当て %v 回、%x, want 0 回、%x", n, d, want)
+	}
+	ch.Sum256Into(data, &d)
+	if n := testing.AllocsPerRun(100, func() { ch.Sum256Into(data, &d) }); n != 0 || d != want {
+		t.Errorf("Sum256Into: 割り当て %v 回、%x, want 0 回、%x", n, d, want)
+	}
+}
+
+func BenchmarkSum256Into(b *testing.B) {
+	for _, bs := range absorbSizes {
+		b.Run(bs.name, func(b *testing.B) {
+			data := benchInput(bs.size)
+			var ch ConcurrentHasher
+			var d [32]byte
+			b.ReportAllocs()
+			b.SetBytes(int64(bs.size))
+			for i := 0; i < b.N; i++ {
+				ch.Sum256Into(data, &d)
+			}
+		})
+	}
+}
+
+// -decimal: 10進数の表示をmath/bigで読み戻し、32バイトに左詰めするとダイジェストに戻る
+func TestDecimalStringRoundTrip(t *testing.T) {
+	digests := [][]byte{make([]byte, 32), bytes.Repeat([]byte{0xff}, 32), append(make([]byte, 3), bytes.Repeat([]byte{0x01}, 29)...)}
+	for i := 0; i < 50; i++ {
+		d := sha3.Sum256([]byte{byte(i)})
+		digests = append(digests, d[:])
+	}
+	for _, d := range digests {
+		s := decimalString(d)
+		n, ok := new(big.Int).SetString(s, 10)
+		if !ok {
+			t.Fatalf("decimalString(%x) = %q は10進数ではありません", d, s)
+		}
+		if got := n.FillBytes(make([]byte, 32)); !bytes.Equal(got, d) {
+			t.Errorf("decimalString(%x) = %s を戻すと %x", d, s, got)
+		}
+	}
+
+	if got, want := decimalString([]byte{0x01, 0x00}), "256"; got != want {
+		t.Errorf("decimalString(0100) = %s, want %s (ビッグエンディアン)", got, want)
+	}
+	if got := decimalString(make([]byte, 32)); got != "0" {
+		t.Errorf("ゼロのダイジェスト = %s, want 0", got)
+	}
+}
+
+// -head は -cache を使わない: 毎回ファイルを読んでバイト数のラベルを付け、既存のキャッシュも書き換えない
+func TestHeadBypassesCache(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "data")
+	cache := filepath.Join(dir, "cache")
+	if err := os.WriteFile(path, []byte("abcdef"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, stderr, code := runCLI(t, "", "-cache", cache, path); code != exitOK {
+		t.Fatalf("キャッシュの作成に失敗: %s", stderr)
+	}
+	cached, err := os.ReadFile(cache)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// サイズと更新時刻を保ったまま内容を変える (キャッシュを使えば古いダイジェストが返る)
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("xyzdef"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, fi.ModTime(), fi.ModTime()); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		head string
+		n    int // 実際にハッシュするバイト数
+	}{{"2", 2}, {"100", 6}} {
+		stdout, stderr, code := runCLI(t, "", "-cache", cache, "-head", tc.head, path)
+		if code != exitOK {
+			t.Fatalf("-head %s: 終了コード %d (%s)", tc.head, code, stderr)
+		}
+		want := sha3.Sum256([]byte("xyzdef")[:tc.n])
+		if line := fmt.Sprintf("head:%d:%x  %s\n", tc.n, want, path); stdout != line {
+			t.Errorf("-head %s = %q, want %q", tc.head, stdout, line)
+		}
+	}
+	if got, _ := os.ReadFile(cache); !bytes.Equal(got, cached) {
+		t.Errorf("-head でキャッシュファイルが書き換えられました:\n%s\nwant:\n%s", got, cached)
+	}
+
+	// -head なしではキャッシュが使われる (サイズと更新時刻が同じなので古いダイジェストになる)
+	stdout, _, _ := runCLI(t, "", "-cache", cache, path)
+	if old := sha3.Sum256([]byte("abcdef")); !strings.HasPrefix(stdout, hex.EncodeToString(old[:])) {
+		t.Errorf("キャッシュを使った結果 %q が古いダイジェスト %x ではありません", stdout, old)
+	}
+}
+
+// -0: 空白や改行を含むファイル名をNUL区切りで受け取り、sha3sum形式 (改行はエスケープ) で出力し、-c で照合できる
+func TestNulTerminatedNames(t *testing.T) {
+	dir := t.TempDir()
+	names := []string{"with space", "with\nnewline", "back\\slash"}
+	var stdin, want strings.Builder
+	for i, name := range names {
+		path := filepath.Join(dir, name)
+		content := []byte(fmt.Sprint("content ", i))
+		if err := os.WriteFile(path, content, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		stdin.WriteString(path + "\x00")
+		d := sha3.Sum256(content)
+		writeSumLine(&want, hex.EncodeToString(d[:]), path)
+	}
+
+	stdout, stderr, code := runCLI(t, stdin.String(), "-0")
+	if code != exitOK {
+		t.Fatalf("終了コード %d (%s)", code, stderr)
+	}
+	if stdout != want.String() {
+		t.Errorf("-0 の出力 = %q, want %q", stdout, want.String())
+	}
+	if !strings.Contains(stdout, "\\") || strings.Count(stdout, "\n") != len(names) {
+		t.Errorf("改行を含む名前がエスケープされていません: %q", stdout)
+	}
+
+	manifest := filepath.Join(t.TempDir(), "manifest")
+	if err := os.WriteFile(manifest, []byte(stdout), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if out, stderr, code := runCLI(t, "", "-c", manifest); code != exitOK {
+		t.Errorf("-c の終了コード %d (%s%s)", code, out, stderr)
+	}
+}
+
+// ParseSpec: 有効な指定はOptionsになり、未知のアルゴリズムや合わない出力長はエラーになる
+func TestParseSpec(t *testing.T) {
+	for _, tc := range []struct {
+		spec string
+		want Options
+	}{
+		{"sha3-256", Options{Algorithm: "sha3-256"}},
+		{" SHA3-384 ", Options{Algorithm: "sha3-384"}},
+		{"sha3-512:64", Options{Algorithm: "sha3-512", Length: 64}},
+		{"shake256:64", Options{Algorithm: "shake256", Length: 64}},
+		{"shake128:1", Options{Algorithm: "shake128", Length: 1}},
+		{"shake128:1000", Options{Algorithm: "shake128", Length: 1000}},
+	} {
+		got, err := ParseSpec(tc.spec)
+		if err != nil || got != tc.want {
+			t.Errorf("ParseSpec(%q) = %+v, %v, want %+v", tc.spec, got, err, tc.want)
+		}
+		if _, err := New(got); err != nil {
+			t.Errorf("New(ParseSpec(%q)): %v", tc.spec, err)
+		}
+	}
+
+	for _, spec := range []string{"", "md5", "sha3-256:", "sha3-256:16", "sha3-256:abc", "shake256:0", "shake256:-1", "shake256:64:1"} {
+		if got, err := ParseSpec(spec); err == nil {
+			t.Errorf("ParseSpec(%q) = %+v, want エラー", spec, got)
+		}
+	}
+}
+
+func TestNewSpongeCapacity256(t *testing.T) {
+	// キャパシティ256ビット (レート168バイト) はSHAKE128と同じなので、ドメインバイトを合わせれば参照実装と比べられる
+	msg := seqBytes(0, 500)
+	sp, err := NewSponge(256, DomainSHAKE, 200)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sp.BlockSize() != 168 {
+		t.Fatalf("BlockSize = %d, want 168", sp.BlockSize())
+	}
+	sp.Write(msg)
+	if got, want := sp.Sum(nil), sha3.SumSHAKE128(msg, 200); !bytes.Equal(got, want) {
+		t.Errorf("キャパシティ256のスポンジ = %x, want %x", got, want)
+	}
+
+	// 標準のキャパシティはそのままSHA3-256になる
+	sp, err = NewSponge(512, DomainSHA3, 32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sp.Write(msg)
+	if got, want := sp.Sum(nil), sha3.Sum256(msg); !bytes.Equal(got, want[:]) {
+		t.Errorf("キャパシティ512のスポンジ = %x, want %x", got, want)
+	}
+
+	for _, capacity := range []int{-8, 1600, 1604, 260} {
+		if _, err := NewSponge(capacity, DomainSHA3, 32); err == nil {
+			t.Errorf("NewSponge(%d) がエラーになりません", capacity)
+		}
+	}
+}
+
+func TestDumpLanes(t *testing.T) {
+	// 絞り出しの最初のブロックは吸収後の状態のレート部分のレーンをそのまま並べたものなので、
+	// SHAKE256の136バイト出力から先頭17レーンの参照値が得られる
+	sp := newShake256()
+	sp.Write([]byte("abc"))
+	st := sp.absorbedState()
+	var buf bytes.Buffer
+	dumpLanes(&buf, &st)
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != 25 {
+		t.Fatalf("%d 行出力されました, want 25", len(lines))
+	}
+	ref := sha3.SumSHAKE256([]byte("abc"), RATE/8)
+	for i := 0; i < RATE/64; i++ {
+		want := fmt.Sprintf("レーン %2d (x=%d, y=%d): %016x", i, i%5, i/5, binary.LittleEndian.Uint64(ref[8*i:]))
+		if lines[i] != want {
+			t.Errorf("%d 行目 = %q, want %q", i, lines[i], want)
+		}
+	}
+}
+
+func TestDumpStateFlag(t *testing.T) {
+	plain, _, code := runCLI(t, "abc\nq\n")
+	if code != exitOK {
+		t.Fatalf("終了コード %d", code)
+	}
+	dumped, _, code := runCLI(t, "abc\nq\n", "-dump-state")
+	if code != exitOK {
+		t.Fatalf("-dump-state の終了コード %d", code)
+	}
+
+	sp := newSponge256()
+	sp.Write([]byte("abc"))
+	st := sp.absorbedState()
+	var lanes bytes.Buffer
+	dumpLanes(&lanes, &st)
+	if !strings.Contains(dumped, "吸収後の内部状態:\n"+lanes.String()) {
+		t.Errorf("-dump-state の出力にレーンがありません:\n%s", dumped)
+	}
+	// ダンプを取り除けば通常の出力と同じ (ダイジェストに影響しない)
+	if got := strings.Replace(dumped, "吸収後の内部状態:\n"+lanes.String(), "", 1); got != plain {
+		t.Errorf("-dump-state で出力が変わりました:\n%s\nwant:\n%s", got, plain)
+	}
+}
+
+func TestKeccakF1600TraceNil(t *testing.T) {
+	var got, want [25]uint64
+	for i := range got {
+		got[i] = uint64(i) * 0x0123456789abcdef
+	}
+	want = got
+	KeccakF1600Trace(&got, nil)
+	KeccakF1600Trace(&want, func(int, string, [25]uint64) {})
+	if got != want {
+		t.Errorf("nilのトレースで置換の結果が変わりました: %x, want %x", got, want)
+	}
+}
+
+func TestSpongeUnmarshalBinaryLength(t *testing.T) {
+	sp := newSponge256()
+	sp.Write([]byte("partial block"))
+	b, err := sp.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var resumed Sponge
+	if err := resumed.UnmarshalBinary(b); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := resumed.Sum(nil), sp.Sum(nil); !bytes.Equal(got, want) {
+		t.Errorf("復元したスポンジのダイジェスト = %x, want %x", got, want)
+	}
+
+	// 固定部分が足りないものと、端数データがレート以上あるものは長さが不正
+	for n := 0; n < spongeMarshaledSize; n++ {
+		if err := new(Sponge).UnmarshalBinary(b[:n]); err == nil {
+			t.Errorf("%d バイトのデータを受け付けました", n)
+		}
+	}
+	long := append(b[:spongeMarshaledSize:spongeMarshaledSize], make([]byte, sp.rate)...)
+	if err := new(Sponge).UnmarshalBinary(long); err == nil {
+		t.Errorf("端数データが %d バイトのデータを受け付けました", sp.rate)
+	}
+	zero := bytes.Clone(b)
+	binary.BigEndian.PutUint32(zero[len(spongeMagic)+2:], 0)
+	if err := new(Sponge).UnmarshalBinary(zero); err == nil {
+		t.Error("出力長が0のデータを受け付けました")
+	}
+}
+
+func TestSumPrefixed256Framing(t *testing.T) {
+	// 長さを前置しない連結では、長さの符号化を自分で付けた入力が正規の入力と同じバイト列になる
+	// (SHA3-256(left_encode(48) || "commit") == sumPrefixed256("commit"))。前置すれば両者は区別される
+	data := []byte("commit")
+	forged := append(leftEncode(uint64(len(data))*8), data...)
+	naive := sha3.Sum256(forged)
+	if !bytes.Equal(naive[:], sumPrefixed256(data)) {
+		t.Fatal("単純な連結の値がsumPrefixed256と一致しません (テストの前提が崩れています)")
+	}
+	if bytes.Equal(sumPrefixed256(forged), sumPrefixed256(data)) {
+		t.Errorf("%x と %x の長さ付きダイジェストが衝突しました", forged, data)
+	}
+}
+
+func TestMultihashPrefix(t *testing.T) {
+	// multicodecの表のコードとvarintのダイジェスト長
+	for _, tc := range []struct {
+		spec, padding string
+		actualSHA256  bool
+		prefix        []byte
+	}{
+		{"sha3-224", "sha3", false, []byte{0x17, 28}},
+		{"sha3-256", "sha3", false, []byte{0x16, 0x20}},
+		{"sha3-384", "sha3", false, []byte{0x15, 48}},
+		{"sha3-512", "sha3", false, []byte{0x14, 64}},
+		{"shake128", "sha3", false, []byte{0x18, 0x20}},
+		{"shake256", "sha3", false, []byte{0x19, 0x40}},
+		{"shake256:200", "sha3", false, []byte{0x19, 0xc8, 0x01}},
+		{"sha3-256", "keccak", false, []byte{0x1b, 0x20}},
+		{"sha3-256", "sha3", true, []byte{0x12, 0x20}},
+	} {
+		alg, err := selectAlgorithm(tc.spec, tc.padding, tc.actualSHA256)
+		if err != nil {
+			t.Fatalf("%s (%s): %v", tc.spec, tc.padding, err)
+		}
+		h := alg.newHash()
+		h.Write([]byte("abc"))
+		digest := h.Sum(nil)
+		got := multihash(alg.mhCode, digest)
+		if !bytes.Equal(got, append(bytes.Clone(tc.prefix), digest...)) {
+			t.Errorf("%s (%s) の先頭 = %x, want %x", alg.name, tc.spec, got[:len(tc.prefix)], tc.prefix)
+		}
+	}
+}
+
+func TestGenVectorsDeterministic(t *testing.T) {
+	newHash := func() hash.Hash { return newSponge256() }
+	var a, b, other strings.Builder
+	genVectors(&a, 8, 42, newHash)
+	genVectors(&b, 8, 42, newHash)
+	genVectors(&other, 8, 43, newHash)
+	if a.String() != b.String() {
+		t.Fatal("同じシードで出力が変わりました")
+	}
+	if a.String() == other.String() {
+		t.Error("シードを変えても出力が同じです")
+	}
+
+	// 各行のダイジェストは入力のSHA3-256
+	for i, line := range strings.Split(strings.TrimSuffix(a.String(), "\n"), "\n") {
+		input, digest, _ := strings.Cut(line, " ")
+		in := mustHex(t, input)
+		if len(in) != i+1 {
+			t.Errorf("%d 行目の入力が %d バイトです", i+1, len(in))
+		}
+		if want := sha3.Sum256(in); digest != hex.EncodeToString(want[:]) {
+			t.Errorf("%d 行目のダイジェスト = %s, want %x", i+1, digest, want)
+		}
+	}
+
+	// 生成方法が変わると外部実装との比較に使った既存のベクタが無効になるため、出力を固定する
+	checkGolden(t, "genvectors_seed42", a.String())
+}
+
+func TestDigestSet(t *testing.T) {
+	var ds DigestSet
+	if ds.Contains([32]byte{}) || ds.Len() != 0 {
+		t.Fatal("ゼロ値の集合が空ではありません")
+	}
+
+	digest := func(i int) [32]byte { return sha3.Sum256(binary.BigEndian.AppendUint32(nil, uint32(i))) }
+	for i := 0; i < 1000; i++ {
+		ds.Add(digest(i))
+	}
+	ds.Add(digest(0)) // 重複は数えない
+	if ds.Len() != 1000 {
+		t.Errorf("Len = %d, want 1000", ds.Len())
+	}
+
+	for i := 0; i < 1000; i++ {
+		d := digest(i)
+		if !ds.Contains(d) || !ds.ContainsBytes(d[:]) {
+			t.Fatalf("%d 番目のダイジェストが見つかりません", i)
+		}
+	}
+	for i := 1000; i < 2000; i++ {
+		d := digest(i)
+		if ds.Contains(d) || ds.ContainsBytes(d[:]) {
+			t.Fatalf("追加していない %d 番目のダイジェストが含まれています", i)
+		}
+	}
+
+	d := digest(1)
+	if ds.ContainsBytes(d[:31]) || ds.ContainsBytes(append(d[:], 0)) {
+		t.Error("32バイトでないスライスが含まれていると判定されました")
+	}
+}
+
+func TestHeadStopsBeforeReadError(t *testing.T) {
+	// 先頭Nバイトの後で読み込みエラーになるリーダーでも、-head N ならエラーに触れずにハッシュできる
+	data := seqBytes(3, 300)
+	errAfter := errors.New("先頭より後を読みました")
+	for _, n := range []int64{1, 136, 300} {
+		fh := &fileHasher{head: n}
+		r, err := fh.content(io.MultiReader(bytes.NewReader(data[:n]), iotest.ErrReader(errAfter)), "stream")
+		if err != nil {
+			t.Fatal(err)
+		}
+		h := newSponge256()
+		if _, err := io.Copy(h, r); err != nil {
+			t.Fatalf("-head %d: %v", n, err)
+		}
+		if got, want := h.Sum(nil), sha3.Sum256(data[:n]); !bytes.Equal(got, want[:]) {
+			t.Errorf("-head %d = %x, want %x", n, got, want)
+		}
+	}
+
+	// -head なしなら同じリーダーのエラーがそのまま返る
+	r, err := (&fileHasher{}).content(io.MultiReader(bytes.NewReader(data), iotest.ErrReader(errAfter)), "stream")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.Copy(newSponge256(), r); !errors.Is(err, errAfter) {
+		t.Errorf("-head なしのエラー = %v, want %v", err, errAfter)
+	}
+}
+
+func TestURLInput(t *testing.T) {
+	body := []byte("served over http")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/ok" {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		w.Write(body)
+	}))
+	defer srv.Close()
+
+	stdout, stderr, code := runCLI(t, "", "-url", srv.URL+"/ok")
+	if code != exitOK {
+		t.Fatalf("終了コード %d: %s", code, stderr)
+	}
+	want := sha3.Sum256(body)
+	if wantLine := hex.EncodeToString(want[:]) + "  " + srv.URL + "/ok\n"; stdout != wantLine {
+		t.Errorf("出力 = %q, want %q", stdout, wantLine)
+	}
+
+	// 2xx以外の応答はハッシュせずにエラーにする
+	stdout, stderr, code = runCLI(t, "", "-url", srv.URL+"/missing")
+	if code != exitIO || stdout != "" || !strings.Contains(stderr, "404") {
+		t.Errorf("404の応答: 終了コード %d, 標準出力 %q, 標準エラー %q", code, stdout, stderr)
+	}
+}
+
+func TestCheckManifestRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	var names []string
+	for i, content := range []string{"alpha", "beta", "gamma"} {
+		name := filepath.Join(dir, fmt.Sprintf("f%d.txt", i))
+		if err := os.WriteFile(name, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, name)
+	}
+
+	fh := &fileHasher{newHash: func() hash.Hash { return newSponge256() }, encode: hex.EncodeToString}
+	var manifest bytes.Buffer
+	if !fh.hashFiles(&manifest, names) {
+		t.Fatal("マニフェストの作成に失敗しました")
+	}
+	manifestPath := filepath.Join(dir, "SHA3SUMS")
+	if err := os.WriteFile(manifestPath, manifest.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var out strings.Builder
+	ok, err := fh.checkManifest(manifestPath, &out)
+	if !ok || err != nil {
+		t.Fatalf("変更前の照合 = %v, %v\n%s", ok, err, out.String())
+	}
+	for _, name := range names {
+		if !strings.Contains(out.String(), name+": OK\n") {
+			t.Errorf("%s が OK になっていません:\n%s", name, out.String())
+		}
+	}
+
+	// 1ファイルだけ書き換えると、そのファイルだけがFAILEDになる
+	if err := os.WriteFile(names[1], []byte("BETA"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	out.Reset()
+	ok, err = fh.checkManifest(manifestPath, &out)
+	if ok || err != nil {
+		t.Fatalf("変更後の照合 = %v, %v, want false, nil", ok, err)
+	}
+	want := names[0] + ": OK\n" + names[1] + ": FAILED\n" + names[2] + ": OK\n"
+	if out.String() != want {
+		t.Errorf("照合結果 = %q, want %q", out.String(), want)
+	}
+}
+
+func TestKeccakPadding(t *testing.T) {
+	// Keccak-256 (Ethereumなどで使われる、FIPS 202以前のパディング) の既知の値
+	for _, tc := range []struct{ in, want string }{
+		{"", "c5d2460186f7233c927e7db2dcc703c0e500b653ca82273b7bfad8045d85a470"},
+		{"abc", "4e03657aea45a94fc7d47ba826c8d667c0d1e6e33a64a036ec44f58fa12d6c45"},
+	} {
+		stdout, stderr, code := runCLI(t, "", "-padding", "keccak", "-s", tc.in)
+		if code != exitOK {
+			t.Fatalf("終了コード %d: %s", code, stderr)
+		}
+		if !strings.HasPrefix(stdout, tc.want+"  ") {
+			t.Errorf("-padding keccak -s %q = %q, want %s", tc.in, stdout, tc.want)
+		}
+
+		sha3Out, _, _ := runCLI(t, "", "-s", tc.in)
+		if sha3Out == stdout {
+			t.Errorf("-s %q: keccakとsha3のパディングで同じダイジェストになりました", tc.in)
+		}
+	}
+}
+
+func TestPadLengthLarge(t *testing.T) {
+	// ビット長 (n*8) ならオーバーフローする長さでも、バイト単位なので正しく求まる
+	const maxInt = int(^uint(0) >> 1)
+	for _, rate := range []int{BlockSize224 * 8, RATE, BlockSize512 * 8, BlockSizeShake128 * 8} {
+		rateBytes := rate / 8
+		for _, limit := range []int{maxInt / 8, maxInt/8 + 1, 1<<31 - 1, maxInt} {
+			n := limit - limit%rateBytes // limit以下で最大のレートの倍数
+			for _, tc := range []struct{ n, want int }{
+				{n, rateBytes},
+				{n - 1, 1},
+				{n - rateBytes + 1, rateBytes - 1},
+			} {
+				if got := padLength(tc.n, rate); got != tc.want {
+					t.Errorf("padLength(%d, %d) = %d, want %d", tc.n, rate, got, tc.want)
+				}
+			}
+		}
+	}
+}
+
+func TestSha3_256InPlaceCapacity(t *testing.T) {
+	for _, n := range []int{0, 1, 135, 136, 200} {
+		need := n + padLength(n, RATE)
+		msg := seqBytes(1, n)
+		want := sha3.Sum256(msg)
+
+		// 容量がちょうど足りていれば計算できる
+		buf := append(make([]byte, 0, need), msg...)
+		if got := sha3_256InPlace(buf); !bytes.Equal(got, want[:]) {
+			t.Errorf("%d バイト = %x, want %x", n, got, want)
+		}
+
+		// 1バイトでも足りなければpanicする
+		short := append(make([]byte, 0, need-1), msg...)
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%d バイト: 容量 %d でpanicしませんでした", n, cap(short))
+				}
+			}()
+			sha3_256InPlace(short)
+		}()
+	}
+}
+
+func TestCSVFieldBoundaries(t *testing.T) {
+	// フィールドの連結が同じ "abc" になる行でも、区切りの位置が違えばダイジェストが異なる
+	stdout, stderr, code := runCLI(t, "a,bc\nab,c\n", "-csv")
+	if code != exitOK {
+		t.Fatalf("終了コード %d: %s", code, stderr)
+	}
+	var want strings.Builder
+	writeSumLine(&want, hex.EncodeToString(SumCSVRow([]string{"a", "bc"})), "-:1")
+	writeSumLine(&want, hex.EncodeToString(SumCSVRow([]string{"ab", "c"})), "-:2")
+	if stdout != want.String() {
+		t.Errorf("出力 = %q, want %q", stdout, want.String())
+	}
+
+	fields := strings.Fields(stdout)
+	if len(fields) != 4 || fields[0] == fields[2] {
+		t.Errorf("\"a,bc\" と \"ab,c\" のダイジェストが同じです:\n%s", stdout)
+	}
+}
+
+func TestWithMetaNames(t *testing.T) {
+	dir := t.TempDir()
+	content := []byte("same content")
+	a, b := filepath.Join(dir, "a.txt"), filepath.Join(dir, "b.txt")
+	sub := filepath.Join(dir, "sub")
+	if err := os.Mkdir(sub, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	c := filepath.Join(sub, "a.txt")
+	for _, name := range []string{a, b, c} {
+		if err := os.WriteFile(name, content, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stdout, stderr, code := runCLI(t, "", "-with-meta", a, b, c)
+	if code != exitOK {
+		t.Fatalf("終了コード %d: %s", code, stderr)
+	}
+	fields := strings.Fields(stdout)
+	if len(fields) != 6 {
+		t.Fatalf("出力が3行ではありません:\n%s", stdout)
+	}
+	// 名前 (パスの最後の要素) が違えば内容が同じでもダイジェストが異なり、同じなら一致する
+	if fields[0] == fields[2] {
+		t.Errorf("a.txt と b.txt のダイジェストが同じです:\n%s", stdout)
+	}
+	if fields[0] != fields[4] {
+		t.Errorf("ディレクトリだけが違う a.txt のダイジェストが異なります:\n%s", stdout)
+	}
+
+	size := binary.BigEndian.AppendUint64(nil, uint64(len(content)))
+	if want := hex.EncodeToString(tupleHash256([][]byte{[]byte("a.txt"), size, content}, nil, 32)); fields[0] != want {
+		t.Errorf("a.txt = %s, want TupleHash256 %s", fields[0], want)
+	}
+}
+
+func TestSum256Vectored(t *testing.T) {
+	data := seqBytes(9, 700)
+	many := [][]byte{data[:0], data[:1], data[1:136], data[136:137], data[137:137], data[137:500], data[500:]}
+	for name, chunks := range map[string][][]byte{
+		"nil":    nil,
+		"empty":  {{}},
+		"single": {data},
+		"many":   many,
+	} {
+		want := sha3_256(bytes.Join(chunks, nil))
+		if got := sum256Vectored(chunks...); !bytes.Equal(got, want) {
+			t.Errorf("%s = %x, want %x", name, got, want)
+		}
+	}
+}
+
+func TestWriteSecurity(t *testing.T) {
+	for _, tc := range []struct {
+		name                string
+		outLen              int
+		collision, preimage int
+	}{
+		{"sha3-256", 32, 128, 256},
+		{"sha3-512", 64, 256, 512},
+		// SHAKE128は短い出力では出力長で、長い出力ではキャパシティ (256ビット) で安全性が決まる
+		{"shake128", 16, 64, 128},
+		{"shake128", 8, 32, 64},
+		{"shake128", 64, 128, 128},
+		{"shake256", 64, 256, 256},
+	} {
+		p, err := paramsFor(tc.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var b strings.Builder
+		writeSecurity(&b, p, tc.outLen)
+		for _, want := range []string{
+			fmt.Sprintf("衝突耐性: %d ビット\n", tc.collision),
+			fmt.Sprintf("原像耐性: %d ビット\n", tc.preimage),
+		} {
+			if !strings.Contains(b.String(), want) {
+				t.Errorf("%s (%d バイト) に %q がありません:\n%s", tc.name, tc.outLen, want, b.String())
+			}
+		}
+	}
+}
+
+func TestStringJoinModes(t *testing.T) {
+	line := func(s string) string {
+		var b strings.Builder
+		d := sha3.Sum256([]byte(s))
+		writeSumLine(&b, hex.EncodeToString(d[:]), s)
+		return b.String()
+	}
+	for _, tc := range []struct {
+		name string
+		args []string
+		want string
+	}{
+		{"個別", []string{"-s", "a", "b", "c"}, line("a") + line("b") + line("c")},
+		{"連結", []string{"-s", "-join", "a", "b", "c"}, line("abc")},
+		{"区切り", []string{"-s", "-join", "-sep", ", ", "a", "b", "c"}, line("a, b, c")},
+		{"改行区切り", []string{"-s", "-join", "-sep", "\n", "a", "b"}, line("a\nb")},
+	} {
+		stdout, stderr, code := runCLI(t, "", tc.args...)
+		if code != exitOK {
+			t.Fatalf("%s: 終了コード %d: %s", tc.name, code, stderr)
+		}
+		if stdout != tc.want {
+			t.Errorf("%s: 出力 = %q, want %q", tc.name, stdout, tc.want)
+		}
+	}
+
+	if _, _, code := runCLI(t, "", "-join", "a"); code != exitUsage {
+		t.Errorf("-s なしの -join の終了コード = %d, want %d", code, exitUsage)
+	}
+}
+
+func TestGunzip(t *testing.T) {
+	plain := bytes.Repeat([]byte("compressible line\n"), 100)
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	zw.Write(plain)
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "data.gz")
+	if err := os.WriteFile(path, gz.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	want := sha3.Sum256(plain)
+	for _, flagName := range []string{"-gunzip", "-decompress"} {
+		stdout, stderr, code := runCLI(t, "", flagName, path)
+		if code != exitOK {
+			t.Fatalf("%s: 終了コード %d: %s", flagName, code, stderr)
+		}
+		if !strings.HasPrefix(stdout, hex.EncodeToString(want[:])+"  ") {
+			t.Errorf("%s = %q, want 展開後の内容のダイジェスト %x", flagName, stdout, want)
+		}
+	}
+
+	// 展開しなければ圧縮されたバイト列のダイジェストになる
+	raw := sha3.Sum256(gz.Bytes())
+	if stdout, _, _ := runCLI(t, "", path); !strings.HasPrefix(stdout, hex.EncodeToString(raw[:])+"  ") {
+		t.Errorf("展開なし = %q, want %x", stdout, raw)
+	}
+}
+
+func TestAlgorithmFromEnv(t *testing.T) {
+	for _, tc := range []struct {
+		algorithm, outputLen string
+		want                 string
+		ok                   bool
+	}{
+		{"", "", "sha3-256", true},
+		{"sha3-512", "", "sha3-512", true},
+		{"shake256", "100", "shake256:100", true},
+		{"", "64", "", false}, // 固定長のアルゴリズムに出力長は指定できない
+		{"shake128:16", "32", "", false},
+		{"md5", "", "", false},
+	} {
+		t.Setenv("SHA3_ALGORITHM", tc.algorithm)
+		t.Setenv("SHA3_OUTPUT_LEN", tc.outputLen)
+		got, err := algorithmFromEnv()
+		if tc.ok && (err != nil || got != tc.want) {
+			t.Errorf("SHA3_ALGORITHM=%q SHA3_OUTPUT_LEN=%q: %q, %v, want %q", tc.algorithm, tc.outputLen, got, err, tc.want)
+		}
+		if !tc.ok && err == nil {
+			t.Errorf("SHA3_ALGORITHM=%q SHA3_OUTPUT_LEN=%q: %q, want エラー", tc.algorithm, tc.outputLen, got)
+		}
+	}
+}
+
+func TestAlgorithmEnvCLI(t *testing.T) {
+	sum512 := sha3.Sum512([]byte("abc"))
+	sum256 := sha3.Sum256([]byte("abc"))
+
+	// 環境変数が既定のアルゴリズムになる
+	stdout, stderr, code := runCLIEnv(t, []string{"SHA3_ALGORITHM=sha3-512"}, "", "-s", "abc")
+	if code != exitOK || !strings.HasPrefix(stdout, hex.EncodeToString(sum512[:])+"  ") {
+		t.Errorf("SHA3_ALGORITHM=sha3-512: 終了コード %d, 出力 %q, 標準エラー %q", code, stdout, stderr)
+	}
+
+	// 明示した -algorithm が優先し、その場合は不正な環境変数も無視する
+	for _, env := range []string{"SHA3_ALGORITHM=sha3-512", "SHA3_ALGORITHM=md5"} {
+		stdout, stderr, code = runCLIEnv(t, []string{env}, "", "-algorithm", "sha3-256", "-s", "abc")
+		if code != exitOK || !strings.HasPrefix(stdout, hex.EncodeToString(sum256[:])+"  ") {
+			t.Errorf("%s -algorithm sha3-256: 終了コード %d, 出力 %q, 標準エラー %q", env, code, stdout, stderr)
+		}
+	}
+
+	// 不正な値はフラグの誤りとして終了する
+	if _, stderr, code := runCLIEnv(t, []string{"SHA3_ALGORITHM=md5"}, "", "-s", "abc"); code != exitUsage || !strings.Contains(stderr, "SHA3_ALGORITHM") {
+		t.Errorf("SHA3_ALGORITHM=md5: 終了コード %d, 標準エラー %q", code, stderr)
+	}
+}
+
+func TestInitUpdateFinal(t *testing.T) {
+	// FIPS 202 のSHA3-256の例 ("abc")
+	want := mustHex(t, "3a985da74fe225b2045c172d6bd390bd855f086e3e9d525b46bfe24511431532")
+	for _, parts := range [][]string{{"abc"}, {"a", "b", "c"}, {"", "ab", "", "c"}} {
+		sp := initSponge(RATE, DomainSHA3)
+		for _, p := range parts {
+			update(sp, []byte(p))
+		}
+		if got := final(sp, 32); !bytes.Equal(got, want) {
+			t.Errorf("%q = %x, want %x", parts, got, want)
+		}
+		// finalは状態を変えないので、もう一度呼んでも同じ値になる
+		if got := final(sp, 32); !bytes.Equal(got, want) {
+			t.Errorf("%q の2回目 = %x, want %x", parts, got, want)
+		}
+	}
+}
+
+func TestFindReducedCollision(t *testing.T) {
+	a, b, ok := findReducedCollision(1)
+	if !ok {
+		t.Fatal("1ラウンドで衝突が見つかりません")
+	}
+	if bytes.Equal(a, b) {
+		t.Fatalf("同じ入力 %x が返りました", a)
+	}
+
+	reduced := func(msg []byte) []byte {
+		sp := newSponge256()
+		sp.SetPermutation(ReducedKeccakP1600(1))
+		sp.Write(msg)
+		return sp.Sum(nil)[:reducedCollisionBytes]
+	}
+	if da, db := reduced(a), reduced(b); !bytes.Equal(da, db) {
+		t.Errorf("%x と %x の切り詰めたダイジェストが異なります: %x, %x", a, b, da, db)
+	}
+	// 24ラウンドのSHA3-256では衝突しない
+	if sha3.Sum256(a) == sha3.Sum256(b) {
+		t.Errorf("%x と %x がSHA3-256でも衝突しました", a, b)
+	}
+}
+
+func TestParamsFor(t *testing.T) {
+	for _, tc := range []struct {
+		name            string
+		size, blockSize int
+		capacity        int
+		domain          byte
+		xof             bool
+		ref             interface{ BlockSize() int }
+	}{
+		{"sha3-224", Size224, BlockSize224, 448, DomainSHA3, false, sha3.New224()},
+		{"sha3-256", Size256, BlockSize256, 512, DomainSHA3, false, sha3.New256()},
+		{"sha3-384", Size384, BlockSize384, 768, DomainSHA3, false, sha3.New384()},
+		{"sha3-512", Size512, BlockSize512, 1024, DomainSHA3, false, sha3.New512()},
+		{"shake128", SizeShake128, BlockSizeShake128, 256, DomainSHAKE, true, sha3.NewSHAKE128()},
+		{"shake256", SizeShake256, BlockSizeShake256, 512, DomainSHAKE, true, sha3.NewSHAKE256()},
+	} {
+		p, err := paramsFor(tc.name)
+		if err != nil {
+			t.Fatalf("paramsFor(%q): %v", tc.name, err)
+		}
+		want := Params{Name: tc.name, Rate: tc.blockSize * 8, Capacity: tc.capacity, Size: tc.size, Rounds: 24, Domain: tc.domain, XOF: tc.xof}
+		if p != want {
+			t.Errorf("paramsFor(%q) = %+v, want %+v", tc.name, p, want)
+		}
+
+		// 定数はNewが返すスポンジのSize/BlockSizeと参照実装のBlockSizeに一致する
+		sp, err := New(Options{Algorithm: tc.name})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sp.Size() != tc.size || sp.BlockSize() != tc.blockSize {
+			t.Errorf("%s: Size/BlockSize = %d/%d, want %d/%d", tc.name, sp.Size(), sp.BlockSize(), tc.size, tc.blockSize)
+		}
+		if tc.ref.BlockSize() != tc.blockSize {
+			t.Errorf("%s: 参照実装のBlockSize = %d, want %d", tc.name, tc.ref.BlockSize(), tc.blockSize)
+		}
+		if h, ok := tc.ref.(hash.Hash); ok && h.Size() != tc.size {
+			t.Errorf("%s: 参照実装のSize = %d, want %d", tc.name, h.Size(), tc.size)
+		}
+	}
+
+	if _, err := paramsFor("sha3-1024"); err == nil {
+		t.Error("未対応の名前でエラーになりません")
+	}
+}
+
+func TestPad101MatchesPad(t *testing.T) {
+	// 空の入力、レートの1バイト手前 (パディングは0x86の1バイト)、レートちょうど (1ブロック追加)
+	for _, n := range []int{0, RATE/8 - 1, RATE / 8} {
+		msg := seqBytes(0x30, n)
+		want := pad(msg, RATE)
+		got := append(bytes.Clone(msg), Pad101(n*8, RATE, DomainSHA3)...)
+		if !bytes.Equal(got, want) {
+			t.Errorf("%d バイト: Pad101 = %x, want %x", n, got[n:], want[n:])
+		}
+		if len(got)%(RATE/8) != 0 {
+			t.Errorf("%d バイト: パディング後の長さ %d がレートの倍数ではありません", n, len(got))
+		}
+	}
+
+	if p := Pad101((RATE/8-1)*8, RATE, DomainSHA3); !bytes.Equal(p, []byte{0x86}) {
+		t.Errorf("レートの1バイト手前のパディング = %x, want 86", p)
+	}
+	if p := Pad101(RATE, RATE, DomainSHA3); len(p) != RATE/8 || p[0] != 0x06 || p[len(p)-1] != 0x80 {
+		t.Errorf("レートちょうどのパディング = %x, want 06 00... 80", p)
+	}
+}
+
+func TestSaltedSum256(t *testing.T) {
+	data := []byte("password")
+	s1, err := SaltedSum256(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s1) != saltSize+32 {
+		t.Fatalf("長さ %d, want %d", len(s1), saltSize+32)
+	}
+	if !VerifySalted(s1, data) {
+		t.Fatal("生成した値を照合できません")
+	}
+	if want := sha3.Sum256(append(bytes.Clone(s1[:saltSize]), data...)); !bytes.Equal(s1[saltSize:], want[:]) {
+		t.Errorf("ダイジェスト = %x, want SHA3-256(ソルト || data) %x", s1[saltSize:], want)
+	}
+
+	// データとソルトのどちらか1ビットでも変われば照合に失敗する
+	flipped := bytes.Clone(data)
+	flipped[0] ^= 1
+	if VerifySalted(s1, flipped) {
+		t.Error("データを変えても照合に成功しました")
+	}
+	badSalt := bytes.Clone(s1)
+	badSalt[0] ^= 1
+	if VerifySalted(badSalt, data) {
+		t.Error("ソルトを変えても照合に成功しました")
+	}
+	if VerifySalted(s1[:len(s1)-1], data) {
+		t.Error("短い値で照合に成功しました")
+	}
+
+	// 呼び出しごとにソルトが変わる
+	s2, err := SaltedSum256(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(s1[:saltSize], s2[:saltSize]) {
+		t.Error("2回の呼び出しで同じソルトになりました")
+	}
+}
+
+func TestEthAddress(t *testing.T) {
+	if got, want := keccak256(nil), mustHex(t, "c5d2460186f7233c927e7db2dcc703c0e500b653ca82273b7bfad8045d85a470"); !bytes.Equal(got, want) {
+		t.Errorf("keccak256(\"\") = %x, want %x", got, want)
+	}
+
+	// 秘密鍵1の公開鍵はsecp256k1の生成元G (x || y)
+	pub := mustHex(t, "79be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798"+
+		"483ada7726a3c4655da4fbfc0e1108a8fd17b448a68554199c47d08ffb10d4b8")
+	if got, want := ethAddress(pub), "0x7E5F4552091A69125d5DfCb7b8C2659029395Bdf"; got != want {
+		t.Errorf("ethAddress(G) = %s, want %s", got, want)
+	}
+}
+
+func TestFollowAppend(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "log")
+	if err := os.WriteFile(path, []byte("first\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	fh := &fileHasher{newHash: func() hash.Hash { return newSponge256() }, encode: hex.EncodeToString}
+	pr, pw := io.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		err := fh.follow(f, pw, "log", 5*time.Millisecond, 500*time.Millisecond)
+		pw.Close()
+		done <- err
+	}()
+
+	lines := bufio.NewReader(pr)
+	wantLine := func(content string) {
+		t.Helper()
+		line, err := lines.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		d := sha3.Sum256([]byte(content))
+		var want strings.Builder
+		writeSumLine(&want, hex.EncodeToString(d[:]), fmt.Sprintf("log (%d バイト)", len(content)))
+		if line != want.String() {
+			t.Errorf("出力 = %q, want %q", line, want.String())
+		}
+	}
+
+	wantLine("first\n")
+
+	// 追記すると、それまでの全体の新しいダイジェストが出力される
+	af, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	af.WriteString("second\n")
+	af.Close()
+	wantLine("first\nsecond\n")
+
+	// 増えないまま -follow-idle が経過すると終了する
+	if rest, _ := io.ReadAll(lines); len(rest) != 0 {
+		t.Errorf("追記していないのに出力されました: %q", rest)
+	}
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSelfHash(t *testing.T) {
+	// runCLIが起動するのはこのテストバイナリ自身なので、親プロセスから同じファイルを読んで比べられる
+	exe, err := os.Executable()
+	if err != nil {
+		t.Skip(err)
+	}
+	b, err := os.ReadFile(exe)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stdout, stderr, code := runCLI(t, "", "-self-hash")
+	if code != exitOK {
+		t.Fatalf("終了コード %d: %s", code, stderr)
+	}
+	var want strings.Builder
+	d := sha3.Sum256(b)
+	writeSumLine(&want, hex.EncodeToString(d[:]), exe)
+	if stdout != want.String() {
+		t.Errorf("出力 = %q, want %q", stdout, want.String())
+	}
+}
+
+// -cache: サイズと更新時刻が同じなら再計算せず、どちらかが変わるか、版や計算条件が違えば読み直す
+// キャッシュが使われたかは、サイズと更新時刻を保ったまま内容を変えて古いダイジェストが返るかで判定する
+func TestDigestCacheInvalidation(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "data")
+	cache := filepath.Join(dir, "cache")
+	mtime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	write := func(content string, mt time.Time) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, mt, mt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	digestOf := func(args ...string) string {
+		t.Helper()
+		stdout, stderr, code := runCLI(t, "", append(args, path)...)
+		if code != exitOK {
+			t.Fatalf("終了コード %d: %s", code, stderr)
+		}
+		digest, _, _ := strings.Cut(stdout, " ")
+		return digest
+	}
+	sum := func(content string) string {
+		d := sha3.Sum256([]byte(content))
+		return hex.EncodeToString(d[:])
+	}
+
+	write("aaaa", mtime)
+	if got := digestOf("-cache", cache); got != sum("aaaa") {
+		t.Fatalf("初回 = %s, want %s", got, sum("aaaa"))
+	}
+
+	// 変更のないファイル (サイズと更新時刻が同じ) はキャッシュから返る
+	write("bbbb", mtime)
+	if got := digestOf("-cache", cache); got != sum("aaaa") {
+		t.Errorf("サイズと更新時刻が同じ = %s, want キャッシュの %s", got, sum("aaaa"))
+	}
+
+	// -no-cache はキャッシュを読まず、キャッシュファイルも書き換えない
+	before, _ := os.ReadFile(cache)
+	if got := digestOf("-cache", cache, "-no-cache"); got != sum("bbbb") {
+		t.Errorf("-no-cache = %s, want %s", got, sum("bbbb"))
+	}
+	if after, _ := os.ReadFile(cache); !bytes.Equal(after, before) {
+		t.Error("-no-cache でキャッシュファイルが書き換えられました")
+	}
+
+	// 更新時刻が変われば読み直す
+	write("bbbb", mtime.Add(time.Second))
+	if got := digestOf("-cache", cache); got != sum("bbbb") {
+		t.Errorf("更新時刻の変更後 = %s, want %s", got, sum("bbbb"))
+	}
+
+	// サイズが変われば読み直す (更新時刻は同じ)
+	write("ccccc", mtime.Add(time.Second))
+	if got := digestOf("-cache", cache); got != sum("ccccc") {
+		t.Errorf("サイズの変更後 = %s, want %s", got, sum("ccccc"))
+	}
+
+	// 1行目の版や計算条件が違うキャッシュは丸ごと使わない (読み直した結果で保存し直す)
+	for _, tc := range []struct{ header, fresh, stale string }{
+		{"sha3-cache 0", "ddddd", "eeeee"},
+		{digestCacheVersion + " algorithm=sha3-512", "fffff", "ggggg"},
+	} {
+		b, err := os.ReadFile(cache)
+		if err != nil {
+			t.Fatal(err)
+		}
+		first, rest, _ := bytes.Cut(b, []byte("\n"))
+		header := tc.header
+		if header == "sha3-cache 0" {
+			header += strings.TrimPrefix(string(first), digestCacheVersion)
+		}
+		if err := os.WriteFile(cache, append([]byte(header+"\n"), rest...), 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		write(tc.fresh, mtime.Add(time.Second)) // サイズと更新時刻はキャッシュの記録と同じ
+		if got := digestOf("-cache", cache); got != sum(tc.fresh) {
+			t.Errorf("1行目が %q のキャッシュ = %s, want %s", header, got, sum(tc.fresh))
+		}
+		write(tc.stale, mtime.Add(time.Second))
+		if got := digestOf("-cache", cache); got != sum(tc.fresh) {
+			t.Errorf("1行目が %q のキャッシュを保存し直した後 = %s, want %s", header, got, sum(tc.fresh))
+		}
+	}
+}
+
+// NIST SP 800-185 のParallelHash256のサンプル (#4、#5)
+func TestParallelHash256Samples(t *testing.T) {
+	x := mustHex(t, "000102030405060710111213141516172021222324252627")
+	for _, tc := range []struct{ customization, want string }{
+		{"", "bc1ef124da34495e948ead207dd9842235da432d2bbc54b4c110e64c451105531b7f2a3e0ce055c02805e7c2de1fb746af97a1dd01f43b824e31b87612410429"},
+		{"Parallel Data", "cdf15289b54f6212b4bc270528b49526006dd9b54e2b6add1ef6900dda3963bb33a72491f236969ca8afaea29c682d47a393c065b38e29fae651a2091c833110"},
+	} {
+		got, err := ParallelHash256(x, 8, []byte(tc.customization), 64)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := mustHex(t, tc.want); !bytes.Equal(got, want) {
+			t.Errorf("S=%q: %x, want %x", tc.customization, got, want)
+		}
+	}
+}
+
+// 並列数によらず、1個のゴルーチンで順に計算した結果と一致する
+func TestParallelHash256ReaderParallelism(t *testing.T) {
+	data := seqBytes(5, 10000)
+	for _, blockSize := range []int{1, 100, 1024, 20000} {
+		serial, err := parallelHash256Reader(bytes.NewReader(data), blockSize, []byte("S"), 32, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for parallelism := 2; parallelism <= 9; parallelism++ {
+			for run := 0; run < 2; run++ {
+				got, err := parallelHash256Reader(iotest.HalfReader(bytes.NewReader(data)), blockSize, []byte("S"), 32, parallelism)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(got, serial) {
+					t.Errorf("ブロック長 %d、並列数 %d (%d 回目) = %x, want %x", blockSize, parallelism, run+1, got, serial)
+				}
+			}
+		}
+	}
+}
+
+func TestValidateDomain(t *testing.T) {
+	for _, d := range []byte{DomainKeccak, DomainCShake, DomainSHA3, DomainRawSHAKE, DomainSHAKE, 0x7f} {
+		if err := validateDomain(d); err != nil {
+			t.Errorf("validateDomain(0x%02x): %v", d, err)
+		}
+		if _, err := New(Options{Algorithm: "sha3-256", Domain: d}); err != nil {
+			t.Errorf("Domain 0x%02x: %v", d, err)
+		}
+	}
+
+	// 0は既定値の意味になるため、Options.Domainでは不正な値にならない
+	if err := validateDomain(0); err == nil {
+		t.Error("validateDomain(0x00) がエラーになりません")
+	}
+	for _, d := range []byte{0x80, 0x86, 0xff} {
+		if err := validateDomain(d); err == nil {
+			t.Errorf("validateDomain(0x%02x) がエラーになりません", d)
+		}
+		if _, err := New(Options{Algorithm: "sha3-256", Domain: d}); err == nil {
+			t.Errorf("Domain 0x%02x の New がエラーになりません", d)
+		}
+		if _, err := NewSponge(512, d, 32); err == nil {
+			t.Errorf("Domain 0x%02x の NewSponge がエラーになりません", d)
+		}
+	}
+}
+
+func TestShowPaddedEmpty(t *testing.T) {
+	// 空の入力のSHA3-256はパディングだけの1ブロック (06 00 ... 00 80) の136バイトになる
+	want := make([]byte, RATE/8)
+	want[0], want[len(want)-1] = DomainSHA3, 0x80
+
+	var b strings.Builder
+	dumpPadded(&b, newSponge256(), nil)
+	if wantOut := "パディング後のメッセージ (136 バイト、レート 136 バイト):\n" + hex.Dump(want); b.String() != wantOut {
+		t.Errorf("dumpPadded = %q, want %q", b.String(), wantOut)
+	}
+
+	// 対話モードの -show-padded でも同じダンプが表示される
+	stdout, stderr, code := runCLI(t, "\nq\n", "-show-padded")
+	if code != exitOK {
+		t.Fatalf("終了コード %d: %s", code, stderr)
+	}
+	if !strings.Contains(stdout, b.String()) {
+		t.Errorf("-show-padded の出力に136バイトのダンプがありません:\n%s", stdout)
+	}
+}
+
+func TestJSONTiming(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data")
+	content := bytes.Repeat([]byte("timing"), 1000)
+	if err := os.WriteFile(path, content, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	want := sha3.Sum256(content)
+
+	for _, timing := range []bool{false, true} {
+		args := []string{"-json", path}
+		if timing {
+			args = []string{"-json", "-timing", path}
+		}
+		stdout, stderr, code := runCLI(t, "", args...)
+		if code != exitOK {
+			t.Fatalf("%v: 終了コード %d: %s", args, code, stderr)
+		}
+		var res map[string]any
+		if err := json.Unmarshal([]byte(stdout), &res); err != nil {
+			t.Fatalf("%v: %v: %q", args, err, stdout)
+		}
+		if res["file"] != path || res["digest"] != hex.EncodeToString(want[:]) {
+			t.Errorf("%v: file/digest = %v/%v", args, res["file"], res["digest"])
+		}
+		ns, hasNS := res["duration_ns"].(float64)
+		mbps, hasMBps := res["throughput_mbps"].(float64)
+		if timing && (!hasNS || !hasMBps || ns < 0 || mbps < 0) {
+			t.Errorf("-timing の出力に所要時間とスループットがありません: %s", stdout)
+		}
+		if !timing && (hasNS || hasMBps) {
+			t.Errorf("-timing なしの出力に所要時間があります: %s", stdout)
+		}
+	}
+
+	// スループットはMB/s (10^6バイト毎秒)
+	var r jsonResult
+	r.setTiming(3_000_000, 2*time.Second)
+	if *r.DurationNS != 2e9 || *r.ThroughputMBps != 1.5 {
+		t.Errorf("setTiming(3000000, 2s) = %d ns, %v MB/s, want 2000000000 ns, 1.5 MB/s", *r.DurationNS, *r.ThroughputMBps)
+	}
+}
+
+func TestTee(t *testing.T) {
+	// 改行やNULを含むバイナリも、標準出力へそのまま (変換せずに) コピーされる
+	input := string(seqBytes(0, 256)) + strings.Repeat("line\r\n", 1000)
+	stdout, stderr, code := runCLI(t, input, "-tee")
+	if code != exitOK {
+		t.Fatalf("終了コード %d: %s", code, stderr)
+	}
+	if stdout != input {
+		t.Errorf("標準出力 (%d バイト) が標準入力 (%d バイト) と一致しません", len(stdout), len(input))
+	}
+
+	var want strings.Builder
+	d := sha3.Sum256([]byte(input))
+	writeSumLine(&want, hex.EncodeToString(d[:]), "-")
+	if !strings.HasSuffix(stderr, want.String()) {
+		t.Errorf("標準エラー = %q, want 末尾に %q", stderr, want.String())
+	}
+}
+
+func TestSumValue256(t *testing.T) {
+	sum := func(v any) string {
+		t.Helper()
+		d, err := SumValue256(v)
+		if err != nil {
+			t.Fatalf("SumValue256(%#v): %v", v, err)
+		}
+		return hex.EncodeToString(d)
+	}
+
+	// マップは挿入順や反復順によらず同じダイジェストになる
+	forward, backward := map[string]int{}, map[string]int{}
+	for i := 0; i < 100; i++ {
+		forward[fmt.Sprint(i)] = i
+		backward[fmt.Sprint(99-i)] = 99 - i
+	}
+	want := sum(forward)
+	for i := 0; i < 10; i++ {
+		if got := sum(backward); got != want {
+			t.Fatalf("挿入順の違うマップ = %s, want %s", got, want)
+		}
+	}
+
+	// 整数は型の幅によらず値が同じなら等しい
+	if sum(int8(1)) != sum(int64(1)) || sum(uint8(1)) != sum(uint64(1)) {
+		t.Error("幅の違う同じ値の整数のダイジェストが異なります")
+	}
+
+	// 値や種類が違えばダイジェストも異なる
+	type point struct{ X, Y int }
+	type other struct{ A, B int }
+	distinct := []any{
+		nil, 1, 2, -1, uint(1), 1.0, true, false, "1", "", []byte("1"), []byte{},
+		[]int{1, 2}, []int{12}, []int{}, []string{"a", "b"}, []string{"ab"},
+		map[string]int{}, map[string]int{"a": 1}, map[string]int{"a": 2}, map[string]int{"b": 1},
+		point{1, 2}, point{2, 1}, other{1, 2}, struct{}{}, complex(1, 0),
+	}
+	seen := map[string]int{}
+	for i, v := range distinct {
+		d := sum(v)
+		if j, ok := seen[d]; ok {
+			t.Errorf("%#v と %#v のダイジェストが同じです", distinct[j], v)
+		}
+		seen[d] = i
+	}
+
+	// nilのスライス・マップは空のものと区別し、nil (タグのみ) として扱う
+	if sum([]int(nil)) == sum([]int{}) || sum(map[string]int(nil)) == sum(map[string]int{}) {
+		t.Error("nilと空のスライス・マップのダイジェストが同じです")
+	}
+	if sum([]int(nil)) != sum(nil) || sum(map[string]int(nil)) != sum(nil) || sum((*int)(nil)) != sum(nil) {
+		t.Error("nilのスライス・マップ・ポインタがnilと同じダイジェストになりません")
+	}
+
+	if _, err := SumValue256(make(chan int)); err == nil {
+		t.Error("チャネルがエラーになりません")
+	}
+}
+
+// -algorithms の各行は、そのアルゴリズムを単独で指定して実行した結果と一致する
+func TestAlgorithmsMatchIndividualRuns(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data")
+	if err := os.WriteFile(path, seqBytes(0, 1000), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	specs := []struct {
+		spec string
+		name string
+		args []string // 単独で実行するときのフラグ
+	}{
+		{"sha3-256", "SHA3-256", []string{"-algorithm", "sha3-256"}},
+		{"sha3-512", "SHA3-512", []string{"-algorithm", "sha3-512"}},
+		{"shake256:32", "SHAKE256", []string{"-algorithm", "shake256:32"}},
+		{"keccak-256", "Keccak-256", []string{"-padding", "keccak"}},
+		{"sha256", "SHA-256", []string{"-actual-sha256"}},
+	}
+	var list []string
+	for _, s := range specs {
+		list = append(list, s.spec)
+	}
+	stdout, stderr, code := runCLI(t, "", "-algorithms", strings.Join(list, ","), path)
+	if code != exitOK {
+		t.Fatalf("終了コード %d: %s", code, stderr)
+	}
+	lines := strings.Split(strings.TrimSuffix(stdout, "\n"), "\n")
+	if len(lines) != len(specs) {
+		t.Fatalf("%d 行出力されました, want %d:\n%s", len(lines), len(specs), stdout)
+	}
+
+	for i, s := range specs {
+		single, stderr, code := runCLI(t, "", append(s.args, path)...)
+		if code != exitOK {
+			t.Fatalf("%v: 終了コード %d: %s", s.args, code, stderr)
+		}
+		digest, _, _ := strings.Cut(single, " ")
+		if want := fmt.Sprintf("%s (%s) = %s", s.name, path, digest); lines[i] != want {
+			t.Errorf("%s: %q, want %q", s.spec, lines[i], want)
+		}
+	}
+
+	// sha256 はSHA-3系とは別に、FIPS 180-4のSHA-256として選ばれる
+	algs, err := parseAlgorithmList("sha256, SHA-256")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, alg := range algs {
+		if _, ok := alg.newHash().(*sha256Digest); !ok || alg.name != "SHA-256" || alg.sponge {
+			t.Errorf("sha256 が %s (%T) になりました", alg.name, alg.newHash())
+		}
+	}
+}
+
+// -autotune でバッファの長さを選んで読んだ結果が、既定の読み込みと同じダイジェストになる
+func TestAutotuneMatchesDefault(t *testing.T) {
+	defer func(minSize, sample int64) { autotuneMinSize, autotuneSample = minSize, sample }(autotuneMinSize, autotuneSample)
+	autotuneMinSize, autotuneSample = 1<<20, 100_000
+
+	// 計測の対象になる最小のサイズより少し大きく、どの候補のバッファ長でも割り切れない長さにする
+	data := bytes.Repeat(seqBytes(0, 1000+7), int(autotuneMinSize)/1000+10)
+	path := filepath.Join(t.TempDir(), "large")
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	want := sha3.Sum256(data)
+
+	for _, autotune := range []bool{false, true} {
+		fh := &fileHasher{newHash: func() hash.Hash { return newSponge256() }, encode: hex.EncodeToString, autotune: autotune}
+		got, err := fh.readAndHash(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, want[:]) {
+			t.Errorf("autotune=%v: %x, want %x", autotune, got, want)
+		}
+		if fh.hashed != int64(len(data)) {
+			t.Errorf("autotune=%v: %d バイトを記録しました, want %d", autotune, fh.hashed, len(data))
+		}
+	}
+}
+
+// 1バイトずつ状態にXORして取り出すスポンジ (レーン単位の処理と比べるための参照用)
+func bytewiseSponge(rate int, dsbyte byte, msg []byte, outLen int) []byte {
+	var s state
+	padded := append(bytes.Clone(msg), padBytes(len(msg), rate*8, dsbyte)...)
+	for off := 0; off < len(padded); off += rate {
+		for j, b := range padded[off : off+rate] {
+			s.a[(j/8)%5][(j/8)/5] ^= uint64(b) << (8 * (j % 8))
+		}
+		s.keccakF1600()
+	}
+	out := make([]byte, 0, outLen)
+	for {
+		for j := 0; j < rate && len(out) < outLen; j++ {
+			out = append(out, byte(s.a[(j/8)%5][(j/8)/5]>>(8*(j%8))))
+		}
+		if len(out) == outLen {
+			return out
+		}
+		s.keccakF1600()
+	}
+}
+
+// レート1096ビット (137バイト、最後のレーンが1バイトだけの部分レーン) のスポンジ
+func TestPartialLaneRate(t *testing.T) {
+	const rate = 1096
+	for _, n := range []int{0, 1, 136, 137, 138, 274, 500} {
+		msg := seqBytes(0x11, n)
+		sp, err := NewSponge(B-rate, DomainSHA3, 300) // 出力も複数ブロックにまたがる
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, c := range [][]byte{msg[:n/3], msg[n/3:]} {
+			sp.Write(c)
+		}
+		want := bytewiseSponge(rate/8, DomainSHA3, msg, 300)
+
+		if got := sp.Sum(nil); !bytes.Equal(got, want) {
+			t.Errorf("%d バイト: Sum = %x, want %x", n, got, want)
+		}
+		squeezed := make([]byte, 300)
+		sq := sp.Squeeze()
+		sq.Read(squeezed[:5])
+		sq.Read(squeezed[5:140])
+		sq.Read(squeezed[140:])
+		if !bytes.Equal(squeezed, want) {
+			t.Errorf("%d バイト: Squeeze = %x, want %x", n, squeezed, want)
+		}
+	}
+
+	// 参照用の実装自体はSHA3-256の値と一致する
+	if got, want := bytewiseSponge(RATE/8, DomainSHA3, []byte("abc"), 32), sha3.Sum256([]byte("abc")); !bytes.Equal(got, want[:]) {
+		t.Errorf("bytewiseSponge(abc) = %x, want %x", got, want)
+	}
+}
+
+func TestZeroize(t *testing.T) {
+	sp := newSponge256()
+	sp.Write(seqBytes(1, 300)) // 2ブロックを吸収し、28バイトが端数バッファに残る
+	buf := sp.buf[:cap(sp.buf)]
+	if len(sp.buf) == 0 || sp.s == (state{}) {
+		t.Fatal("吸収後の状態か端数バッファが空です (テストの前提が崩れています)")
+	}
+
+	sp.Zeroize()
+	if sp.s != (state{}) {
+		t.Errorf("レーンが消去されていません: %x", sp.s.a)
+	}
+	if len(sp.buf) != 0 || !bytes.Equal(buf, make([]byte, len(buf))) {
+		t.Errorf("端数バッファが消去されていません: len %d, %x", len(sp.buf), buf)
+	}
+	// 消去後は新しいスポンジとして使える
+	sp.Write([]byte("abc"))
+	if got, want := sp.Sum(nil), sha3.Sum256([]byte("abc")); !bytes.Equal(got, want[:]) {
+		t.Errorf("消去後のダイジェスト = %x, want %x", got, want)
+	}
+
+	// WriteNoCopyで借りた呼び出し側のスライスには触れない
+	borrowed := seqBytes(7, 50)
+	sp = newSponge256()
+	sp.WriteNoCopy(borrowed)
+	sp.Zeroize()
+	if !bytes.Equal(borrowed, seqBytes(7, 50)) {
+		t.Error("WriteNoCopyで渡したスライスが書き換えられました")
+	}
+	if sp.buf != nil || sp.borrowed {
+		t.Error("借りたスライスへの参照が残っています")
+	}
+
+	sq := newShake256().Squeeze()
+	sq.Read(make([]byte, 200))
+	sq.Zeroize()
+	if sq.s != (state{}) || sq.off != 0 {
+		t.Errorf("Squeezerが消去されていません: off %d, %x", sq.off, sq.s.a)
+	}
+}